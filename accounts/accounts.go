@@ -26,6 +26,16 @@ import (
 // That means re-ordering of (Nimiq) transactions in a block could theoretically lead to different state outcomes.
 type Accounts struct {
 	Tree *tree.PMTree
+	// NetworkID is the network that transactions must be signed for.
+	NetworkID uint8
+	// SkipVerify disables transaction signature checks in Push,
+	// for blocks that were already checked with a BatchVerifier.
+	SkipVerify bool
+	// TxCache, if set, rejects transactions already included in recent blocks.
+	// Push and Revert keep it in sync with the state.
+	TxCache *TxCache
+
+	journal *journal // set while pushing a block
 }
 
 // NewAccounts creates a new Accounts trie interface backed by the specified store.
@@ -36,7 +46,52 @@ func NewAccounts(tree *tree.PMTree) *Accounts {
 }
 
 // Push pushes a new block to the state.
-func (a *Accounts) Push(block *wire.Block) error {
+// The returned receipt can be passed to Revert to undo the block.
+//
+// The block is applied atomically:
+// If an error is returned, the state is left unchanged.
+func (a *Accounts) Push(block *wire.Block) (*Receipt, error) {
+	if a.TxCache != nil {
+		if err := a.TxCache.check(block); err != nil {
+			return nil, err
+		}
+	}
+	staged, overlay := a.stage()
+	staged.journal = newJournal(block.Header.Height)
+	staged.Tree.Begin()
+	if err := staged.push(block); err != nil {
+		// Staged changes get discarded with the overlay.
+		return nil, err
+	}
+	staged.Tree.Commit()
+	overlay.Flush()
+	if a.TxCache != nil {
+		a.TxCache.PushBlock(block)
+	}
+	return &staged.journal.receipt, nil
+}
+
+// stage returns a copy of the accounts that buffers all writes
+// in an overlay on top of the current state.
+func (a *Accounts) stage() (*Accounts, *tree.OverlayStore) {
+	overlay := tree.NewOverlayStore(a.Tree.Store)
+	staged := *a
+	staged.Tree = &tree.PMTree{
+		Store:           overlay,
+		ParallelHashing: a.Tree.ParallelHashing,
+	}
+	return &staged, overlay
+}
+
+func (a *Accounts) push(block *wire.Block) error {
+	if err := a.checkValidity(block); err != nil {
+		return err
+	}
+	if !a.SkipVerify {
+		if err := a.verifyTxs(block); err != nil {
+			return err
+		}
+	}
 	if err := a.pushSenders(block, func(acc wire.Account, tx wire.Tx, height uint32) (wire.Account, error) {
 		return acc.ApplyOutgoingTx(tx, height)
 	}); err != nil {
@@ -60,9 +115,25 @@ func (a *Accounts) Push(block *wire.Block) error {
 }
 
 // Revert undoes all changes a block does to the state.
-// It is has the exact inverse effects of Push.
-func (a *Accounts) Revert(block *wire.Block) error {
-	panic("not implemented")
+// It is has the exact inverse effects of Push,
+// given the receipt returned when pushing the block.
+func (a *Accounts) Revert(block *wire.Block, receipt *Receipt) error {
+	if err := checkReceipt(block, receipt); err != nil {
+		return err
+	}
+	if a.TxCache != nil {
+		if err := a.TxCache.RevertBlock(block); err != nil {
+			return err
+		}
+	}
+	// Restore accounts in reverse order of modification.
+	a.Tree.Begin()
+	for i := len(receipt.Accounts) - 1; i >= 0; i-- {
+		prior := &receipt.Accounts[i]
+		a.Tree.PutEntry(&prior.Address, prior.Value)
+	}
+	a.Tree.Commit()
+	return nil
 }
 
 // GetAccount looks up an account by its address.
@@ -84,7 +155,51 @@ func (a *Accounts) GetAccount(addr *[20]byte) wire.Account {
 	return acc.Account
 }
 
+// ForEach calls fn for each existing account in address order.
+// Iteration stops at the first error, which is returned.
+// The accounts must not be modified during iteration.
+func (a *Accounts) ForEach(fn func(addr [20]byte, acc wire.Account) error) error {
+	it := a.Tree.Iterate(nil)
+	for it.Next() {
+		addr := it.Key()
+		acc, err := decodeAccount(it.Value())
+		if err != nil {
+			return fmt.Errorf("failed to read account %s: %w", hex.EncodeToString(addr[:]), err)
+		}
+		if err := fn(addr, acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check verifies the integrity of an accounts tree in the store,
+// including that every leaf holds a valid account.
+func Check(store tree.Store) *tree.CheckResult {
+	return tree.Check(store, func(value []byte) error {
+		_, err := decodeAccount(value)
+		return err
+	})
+}
+
+// decodeAccount parses a serialized account from the tree.
+func decodeAccount(buf []byte) (wire.Account, error) {
+	var acc wire.WrapAccount
+	n, err := acc.UnmarshalBESerial(buf)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(buf) {
+		return nil, fmt.Errorf("%d trailing bytes", len(buf)-n)
+	}
+	return acc.Account, nil
+}
+
+// PutAccount writes an account to the state.
 func (a *Accounts) PutAccount(addr *[20]byte, acc wire.Account) {
+	if a.journal != nil {
+		a.journal.record(addr, a.Tree.GetEntry(addr))
+	}
 	// Implicitly prune empty basic accounts.
 	if acc == nil || (acc.Type() == wire.AccountBasic && acc.IsEmpty()) {
 		a.Tree.PutEntry(addr, nil)
@@ -102,7 +217,7 @@ func (a *Accounts) pushSenders(block *wire.Block, op AccountOp) error {
 	for _, tx := range block.Body.Txs {
 		content := tx.Tx.AsTxContent()
 		if err := a.pushTx(&content.Sender, content.SenderType, tx.Tx, block.Header.Height, op); err != nil {
-			return err
+			return &InvalidForSenderError{Address: content.Sender, Err: err}
 		}
 	}
 	return nil
@@ -116,7 +231,7 @@ func (a *Accounts) pushRecipients(block *wire.Block, op AccountOp) error {
 			recipientType = content.RecipientType
 		}
 		if err := a.pushTx(&content.Recipient, recipientType, tx.Tx, block.Header.Height, op); err != nil {
-			return err
+			return &InvalidForRecipientError{address: content.Recipient, error: err}
 		}
 	}
 	return nil
@@ -146,10 +261,14 @@ func (a *Accounts) pushInherents(block *wire.Block) error {
 		Value:               reward,
 		ValidityStartHeight: block.Header.Height,
 	}
-	return a.pushTx(&block.Body.MinerAddr, wire.AccountBasic, &coinbase, block.Header.Height,
+	err := a.pushTx(&block.Body.MinerAddr, wire.AccountBasic, &coinbase, block.Header.Height,
 		func(acc wire.Account, tx wire.Tx, height uint32) (wire.Account, error) {
 			return acc.ApplyIncomingTx(tx, height)
 		})
+	if err != nil {
+		return &InvalidForRecipientError{address: block.Body.MinerAddr, error: err}
+	}
+	return nil
 }
 
 // pruneAccounts removes accounts from the state.
@@ -267,6 +386,21 @@ func (a *AccountTypeMismatchError) Error() string {
 		a.Got, a.Expected)
 }
 
+// InvalidTxError is returned when a transaction in a block fails verification.
+type InvalidTxError struct {
+	Index int
+	Err   error
+}
+
+func (i *InvalidTxError) Error() string {
+	return fmt.Sprintf("invalid tx %d: %s", i.Index, i.Err)
+}
+
+func (i *InvalidTxError) Unwrap() error {
+	return i.Err
+}
+
+// InvalidForSenderError is returned when a transaction cannot be applied to its sender.
 type InvalidForSenderError struct {
 	Address [20]byte
 	Err     error
@@ -280,6 +414,7 @@ func (i *InvalidForSenderError) Unwrap() error {
 	return i.Err
 }
 
+// InvalidForRecipientError is returned when a transaction cannot be applied to its recipient.
 type InvalidForRecipientError struct {
 	address [20]byte
 	error   error
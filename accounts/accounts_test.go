@@ -1,9 +1,12 @@
 package accounts
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
 	"terorie.dev/nimiq/tree"
 	"terorie.dev/nimiq/wallet"
 	"terorie.dev/nimiq/wire"
@@ -11,17 +14,19 @@ import (
 
 func TestAccounts_Pruning(t *testing.T) {
 	accounts := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accounts.NetworkID = 4
 	w := wallet.GenerateBasic()
 
 	// Give a block reward.
-	require.NoError(t, accounts.Push(&wire.Block{
+	_, err := accounts.Push(&wire.Block{
 		Header: wire.BlockHeader{
 			Height: 1,
 		},
 		Body: &wire.BlockBody{
 			MinerAddr: w.GetAddress(),
 		},
-	}))
+	})
+	require.NoError(t, err)
 
 	// Create vesting contract.
 	createTx := wire.ExtendedTx{
@@ -41,7 +46,7 @@ func TestAccounts_Pruning(t *testing.T) {
 		NetworkID: 4,
 	}
 	w.SignExtendedTx(&createTx)
-	require.NoError(t, accounts.Push(&wire.Block{
+	_, err = accounts.Push(&wire.Block{
 		Header: wire.BlockHeader{
 			Height: 2,
 		},
@@ -52,7 +57,338 @@ func TestAccounts_Pruning(t *testing.T) {
 				{Tx: &createTx},
 			},
 		},
-	}))
+	})
+	require.NoError(t, err)
 
 	// TODO Empty vesting contract without pruning it.
 }
+
+func TestAccounts_Revert(t *testing.T) {
+	accounts := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accounts.NetworkID = 4
+	w := wallet.GenerateBasic()
+	emptyHash := accounts.Tree.Hash()
+
+	block1 := &wire.Block{
+		Header: wire.BlockHeader{
+			Height: 1,
+		},
+		Body: &wire.BlockBody{
+			MinerAddr: w.GetAddress(),
+		},
+	}
+	receipt1, err := accounts.Push(block1)
+	require.NoError(t, err)
+	block1Hash := accounts.Tree.Hash()
+
+	// Move funds to a basic account and a vesting contract.
+	sendTx := wire.BasicTx{
+		SenderPubKey:        w.GetPublicKey(),
+		Recipient:           [20]byte{0x01},
+		Value:               1000,
+		Fee:                 10,
+		ValidityStartHeight: 1,
+		NetworkID:           4,
+	}
+	require.NoError(t, w.SignBasicTx(&sendTx))
+	createTx := wire.ExtendedTx{
+		Sender:              w.GetAddress(),
+		SenderType:          wire.AccountBasic,
+		Recipient:           [20]byte{0x02},
+		RecipientType:       wire.AccountVesting,
+		Value:               100,
+		ValidityStartHeight: 1,
+		Flags:               wire.TxFlagContractCreation,
+		Data:                append(make([]byte, 20), 0x00, 0x00, 0x00, 0x01),
+		NetworkID:           4,
+	}
+	require.NoError(t, w.SignExtendedTx(&createTx))
+	block2 := &wire.Block{
+		Header: wire.BlockHeader{
+			Height: 2,
+		},
+		Body: &wire.BlockBody{
+			MinerAddr: [20]byte{0x03},
+			Txs: []wire.WrapTx{
+				{Tx: &sendTx},
+				{Tx: &createTx},
+			},
+		},
+	}
+	receipt2, err := accounts.Push(block2)
+	require.NoError(t, err)
+	assert.Len(t, receipt2.Accounts, 4)
+
+	// Receipts survive a serialization round trip.
+	receiptBuf, err := beserial.Marshal(nil, receipt2)
+	require.NoError(t, err)
+	var receipt2Copy Receipt
+	require.NoError(t, beserial.UnmarshalFull(receiptBuf, &receipt2Copy))
+	assert.Equal(t, receipt2.Height, receipt2Copy.Height)
+	assert.Len(t, receipt2Copy.Accounts, len(receipt2.Accounts))
+
+	// Receipts do not apply to other blocks.
+	assert.Error(t, accounts.Revert(block2, receipt1))
+
+	require.NoError(t, accounts.Revert(block2, &receipt2Copy))
+	assert.Equal(t, block1Hash, accounts.Tree.Hash())
+	require.NoError(t, accounts.Revert(block1, receipt1))
+	assert.Equal(t, emptyHash, accounts.Tree.Hash())
+}
+
+func TestAccounts_PushAtomic(t *testing.T) {
+	accounts := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accounts.NetworkID = 4
+	w := wallet.GenerateBasic()
+
+	_, err := accounts.Push(&wire.Block{
+		Header: wire.BlockHeader{
+			Height: 1,
+		},
+		Body: &wire.BlockBody{
+			MinerAddr: w.GetAddress(),
+		},
+	})
+	require.NoError(t, err)
+	balance := accounts.GetAccount(&[20]byte{0x01}).Balance()
+	hash := accounts.Tree.Hash()
+
+	// The second transaction overspends after the first one was applied.
+	reward := accounts.GetAccount(ptrAddr(w.GetAddress())).Balance()
+	tx1 := wire.BasicTx{
+		SenderPubKey:        w.GetPublicKey(),
+		Recipient:           [20]byte{0x01},
+		Value:               reward - 1,
+		ValidityStartHeight: 1,
+		NetworkID:           4,
+	}
+	require.NoError(t, w.SignBasicTx(&tx1))
+	tx2 := tx1
+	tx2.Value = 2
+	require.NoError(t, w.SignBasicTx(&tx2))
+	_, err = accounts.Push(&wire.Block{
+		Header: wire.BlockHeader{
+			Height: 2,
+		},
+		Body: &wire.BlockBody{
+			MinerAddr: w.GetAddress(),
+			Txs: []wire.WrapTx{
+				{Tx: &tx1},
+				{Tx: &tx2},
+			},
+		},
+	})
+	var overspend *wire.OverspendError
+	require.ErrorAs(t, err, &overspend)
+
+	// The state is unchanged.
+	assert.Equal(t, hash, accounts.Tree.Hash())
+	assert.Equal(t, balance, accounts.GetAccount(&[20]byte{0x01}).Balance())
+}
+
+func ptrAddr(addr [20]byte) *[20]byte {
+	return &addr
+}
+
+func TestAccounts_VerifyTxs(t *testing.T) {
+	accounts := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accounts.NetworkID = 4
+	w := wallet.GenerateBasic()
+
+	_, err := accounts.Push(&wire.Block{
+		Header: wire.BlockHeader{
+			Height: 1,
+		},
+		Body: &wire.BlockBody{
+			MinerAddr: w.GetAddress(),
+		},
+	})
+	require.NoError(t, err)
+
+	pushTx := func(tx wire.Tx) error {
+		_, err := accounts.Push(&wire.Block{
+			Header: wire.BlockHeader{
+				Height: 2,
+			},
+			Body: &wire.BlockBody{
+				MinerAddr: w.GetAddress(),
+				Txs:       []wire.WrapTx{{Tx: tx}},
+			},
+		})
+		return err
+	}
+	tx := wire.BasicTx{
+		SenderPubKey:        w.GetPublicKey(),
+		Recipient:           [20]byte{0x01},
+		Value:               100,
+		ValidityStartHeight: 1,
+		NetworkID:           4,
+	}
+	t.Run("BadSignature", func(t *testing.T) {
+		tx := tx
+		require.NoError(t, w.SignBasicTx(&tx))
+		tx.Value++
+		assert.ErrorIs(t, pushTx(&tx), wire.ErrInvalidSignature)
+	})
+	t.Run("OtherNetwork", func(t *testing.T) {
+		tx := tx
+		tx.NetworkID = 1
+		require.NoError(t, w.SignBasicTx(&tx))
+		var mismatch *wire.NetworkMismatchError
+		assert.ErrorAs(t, pushTx(&tx), &mismatch)
+	})
+	t.Run("WrongSigner", func(t *testing.T) {
+		ext := tx.AsExtendedTx()
+		require.NoError(t, wallet.GenerateBasic().SignExtendedTx(&ext))
+		assert.ErrorIs(t, pushTx(&ext), wire.ErrWrongSigner)
+	})
+	t.Run("Valid", func(t *testing.T) {
+		ext := tx.AsExtendedTx()
+		require.NoError(t, w.SignExtendedTx(&ext))
+		assert.NoError(t, pushTx(&ext))
+	})
+}
+
+func TestAccounts_ContractIncoming(t *testing.T) {
+	accounts := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accounts.NetworkID = 4
+	w := wallet.GenerateBasic()
+
+	_, err := accounts.Push(&wire.Block{
+		Header: wire.BlockHeader{
+			Height: 1,
+		},
+		Body: &wire.BlockBody{
+			MinerAddr: w.GetAddress(),
+		},
+	})
+	require.NoError(t, err)
+
+	vestingAddr := [20]byte{0x01}
+	htlcAddr := [20]byte{0x02}
+	createVesting := wire.ExtendedTx{
+		Sender:              w.GetAddress(),
+		SenderType:          wire.AccountBasic,
+		Recipient:           vestingAddr,
+		RecipientType:       wire.AccountVesting,
+		Value:               100,
+		ValidityStartHeight: 1,
+		Flags:               wire.TxFlagContractCreation,
+		Data:                append(make([]byte, 20), 0x00, 0x00, 0x00, 0x01),
+		NetworkID:           4,
+	}
+	require.NoError(t, w.SignExtendedTx(&createVesting))
+	htlcData := make([]byte, 78)
+	htlcData[40] = wire.HashBlake2b
+	htlcData[73] = 1 // hash count
+	createHTLC := wire.ExtendedTx{
+		Sender:              w.GetAddress(),
+		SenderType:          wire.AccountBasic,
+		Recipient:           htlcAddr,
+		RecipientType:       wire.AccountHTLC,
+		Value:               100,
+		ValidityStartHeight: 1,
+		Flags:               wire.TxFlagContractCreation,
+		Data:                htlcData,
+		NetworkID:           4,
+	}
+	require.NoError(t, w.SignExtendedTx(&createHTLC))
+	_, err = accounts.Push(&wire.Block{
+		Header: wire.BlockHeader{
+			Height: 2,
+		},
+		Body: &wire.BlockBody{
+			MinerAddr: w.GetAddress(),
+			Txs: []wire.WrapTx{
+				{Tx: &createVesting},
+				{Tx: &createHTLC},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, contract := range []struct {
+		name    string
+		addr    [20]byte
+		accType uint8
+	}{
+		{"Vesting", vestingAddr, wire.AccountVesting},
+		{"HTLC", htlcAddr, wire.AccountHTLC},
+	} {
+		t.Run(contract.name, func(t *testing.T) {
+			tx := wire.ExtendedTx{
+				Sender:              w.GetAddress(),
+				SenderType:          wire.AccountBasic,
+				Recipient:           contract.addr,
+				RecipientType:       contract.accType,
+				Value:               10,
+				ValidityStartHeight: 2,
+				NetworkID:           4,
+			}
+			require.NoError(t, w.SignExtendedTx(&tx))
+			_, err := accounts.Push(&wire.Block{
+				Header: wire.BlockHeader{
+					Height: 3,
+				},
+				Body: &wire.BlockBody{
+					MinerAddr: w.GetAddress(),
+					Txs:       []wire.WrapTx{{Tx: &tx}},
+				},
+			})
+			var invalid *InvalidForRecipientError
+			require.ErrorAs(t, err, &invalid)
+			assert.ErrorIs(t, err, wire.ErrIllegalIncoming)
+		})
+	}
+}
+
+func TestAccounts_ForEach(t *testing.T) {
+	accs := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accs.PutAccount(&[20]byte{0x02}, &wire.BasicAccount{Value: 20})
+	accs.PutAccount(&[20]byte{0x01}, &wire.BasicAccount{Value: 10})
+	accs.PutAccount(&[20]byte{0x03}, &wire.VestingAccount{Value: 30})
+
+	// Total supply audit.
+	var addrs [][20]byte
+	var supply uint64
+	require.NoError(t, accs.ForEach(func(addr [20]byte, acc wire.Account) error {
+		addrs = append(addrs, addr)
+		supply += acc.Balance()
+		return nil
+	}))
+	assert.Equal(t, [][20]byte{{0x01}, {0x02}, {0x03}}, addrs)
+	assert.Equal(t, uint64(60), supply)
+
+	// Errors stop the iteration.
+	stop := errors.New("stop")
+	var count int
+	err := accs.ForEach(func([20]byte, wire.Account) error {
+		count++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestCheck(t *testing.T) {
+	store := tree.NewMemStore()
+	accs := NewAccounts(&tree.PMTree{Store: store})
+	accs.PutAccount(&[20]byte{0x01}, &wire.BasicAccount{Value: 10})
+	accs.PutAccount(&[20]byte{0x02}, &wire.BasicAccount{Value: 20})
+	result := Check(store)
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, 2, result.Leaves)
+
+	// Trailing bytes after an account are invalid.
+	prefix := tree.KeyToNibbles(&[20]byte{0x02})
+	leaf := store.GetNode(prefix).(*tree.Leaf)
+	store.PutNode(prefix, &tree.Leaf{Prefix: prefix, Value: append(append([]byte(nil), leaf.Value...), 0x00)})
+	var invalid []*tree.Problem
+	for _, problem := range Check(store).Problems {
+		if errors.Is(problem, tree.ErrInvalidLeaf) {
+			invalid = append(invalid, problem)
+		}
+	}
+	require.Len(t, invalid, 1)
+	assert.Equal(t, prefix, invalid[0].Prefix)
+}
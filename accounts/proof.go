@@ -0,0 +1,43 @@
+package accounts
+
+import (
+	"fmt"
+
+	"terorie.dev/nimiq/tree"
+	"terorie.dev/nimiq/wire"
+)
+
+// Prove creates a proof for the accounts at the given addresses,
+// verifiable against the current accounts hash.
+func (a *Accounts) Prove(addrs []*[20]byte) *wire.AccountsProof {
+	return a.Tree.Prove(addrs).ToWire()
+}
+
+// VerifyProof checks an accounts proof against the accounts hash
+// and returns the proven accounts at the given addresses.
+// Like GetAccount, it returns the initial account for addresses that do not exist.
+func VerifyProof(proof *wire.AccountsProof, accountsHash [32]byte, addrs []*[20]byte) ([]wire.Account, error) {
+	p, err := tree.ProofFromWire(proof)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Verify(accountsHash); err != nil {
+		return nil, err
+	}
+	accs := make([]wire.Account, len(addrs))
+	for i, addr := range addrs {
+		buf, err := p.GetEntry(addr)
+		if err != nil {
+			return nil, fmt.Errorf("account %x: %w", addr[:], err)
+		}
+		if len(buf) == 0 {
+			accs[i] = &wire.InitialAccount
+			continue
+		}
+		accs[i], err = decodeAccount(buf)
+		if err != nil {
+			return nil, fmt.Errorf("account %x: %w", addr[:], err)
+		}
+	}
+	return accs, nil
+}
@@ -0,0 +1,28 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/tree"
+	"terorie.dev/nimiq/wire"
+)
+
+func TestAccounts_Prove(t *testing.T) {
+	accs := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	addr1, addr2, missing := &[20]byte{0x01}, &[20]byte{0x02}, &[20]byte{0x03}
+	accs.PutAccount(addr1, &wire.BasicAccount{Value: 100})
+	accs.PutAccount(addr2, &wire.BasicAccount{Value: 200})
+	hash := accs.Tree.Hash()
+
+	proof := accs.Prove([]*[20]byte{addr2, missing})
+	proven, err := VerifyProof(proof, hash, []*[20]byte{addr2, missing})
+	require.NoError(t, err)
+	assert.Equal(t, []wire.Account{&wire.BasicAccount{Value: 200}, &wire.InitialAccount}, proven)
+
+	_, err = VerifyProof(proof, hash, []*[20]byte{addr1})
+	assert.ErrorIs(t, err, tree.ErrNotProven)
+	_, err = VerifyProof(proof, [32]byte{}, []*[20]byte{addr2})
+	assert.ErrorIs(t, err, tree.ErrProofHashMismatch)
+}
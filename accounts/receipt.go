@@ -0,0 +1,76 @@
+package accounts
+
+import (
+	"fmt"
+
+	"terorie.dev/nimiq/wire"
+)
+
+// Receipt records the parts of the state that a block overwrote.
+// It is produced by Push and consumed by Revert.
+//
+// The receipt holds the previous encoding of every account touched by the block.
+// That covers accounts pruned by the block, contracts as they were before
+// being modified or created, and the previous balances of basic accounts.
+// Receipts can be serialized with beserial and stored next to the block.
+type Receipt struct {
+	Height   uint32
+	Accounts []PriorAccount `beserial:"len_tag=uint16"`
+}
+
+// PriorAccount is the state of an account before a block touched it.
+type PriorAccount struct {
+	Address [20]byte
+	// Value is the encoded account in the tree.
+	// Empty if the account did not exist.
+	Value []byte `beserial:"len_tag=uint16"`
+}
+
+// journal collects the prior state of accounts while pushing a block.
+type journal struct {
+	receipt Receipt
+	touched map[[20]byte]struct{}
+}
+
+func newJournal(height uint32) *journal {
+	return &journal{
+		receipt: Receipt{Height: height},
+		touched: make(map[[20]byte]struct{}),
+	}
+}
+
+// record saves the current state of an account, unless it was already saved.
+func (j *journal) record(addr *[20]byte, value []byte) {
+	if _, ok := j.touched[*addr]; ok {
+		return
+	}
+	j.touched[*addr] = struct{}{}
+	j.receipt.Accounts = append(j.receipt.Accounts, PriorAccount{
+		Address: *addr,
+		Value:   value,
+	})
+}
+
+// checkReceipt verifies that the receipt covers all accounts touched by the block.
+func checkReceipt(block *wire.Block, receipt *Receipt) error {
+	if receipt.Height != block.Header.Height {
+		return fmt.Errorf("receipt height %d does not match block height %d",
+			receipt.Height, block.Header.Height)
+	}
+	covered := make(map[[20]byte]struct{}, len(receipt.Accounts))
+	for _, acc := range receipt.Accounts {
+		covered[acc.Address] = struct{}{}
+	}
+	touched := []*[20]byte{&block.Body.MinerAddr}
+	for _, tx := range block.Body.Txs {
+		sender, _ := tx.Tx.GetSender()
+		recipient, _ := tx.Tx.GetRecipient()
+		touched = append(touched, sender, recipient)
+	}
+	for _, addr := range touched {
+		if _, ok := covered[*addr]; !ok {
+			return fmt.Errorf("receipt does not cover account %x", *addr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+
+	"terorie.dev/nimiq/policy"
+	"terorie.dev/nimiq/wire"
+)
+
+// ErrDuplicateTx is returned when a transaction was already included
+// in a block within the transaction validity window.
+var ErrDuplicateTx = errors.New("transaction already included")
+
+// TxCache remembers the transactions of the blocks in the validity window.
+// Since transactions are only valid within that window,
+// the cache is sufficient to detect all replays.
+//
+// The cache follows the chain: Blocks get added by PushBlock and removed by RevertBlock.
+// After reverting, blocks that fell out of the window can be added back with PrependBlock.
+type TxCache struct {
+	blocks []cachedBlock // ordered by height, oldest first
+	txs    map[[32]byte]struct{}
+}
+
+type cachedBlock struct {
+	height uint32
+	hashes [][32]byte
+}
+
+// NewTxCache creates an empty transaction cache.
+func NewTxCache() *TxCache {
+	return &TxCache{txs: make(map[[32]byte]struct{})}
+}
+
+// Contains checks whether a transaction hash is in the cache.
+func (c *TxCache) Contains(hash *[32]byte) bool {
+	_, ok := c.txs[*hash]
+	return ok
+}
+
+// Missing returns the number of blocks missing to cover a full validity window.
+func (c *TxCache) Missing() int {
+	return int(policy.TxValidityWindow) - len(c.blocks)
+}
+
+// Height returns the height of the last block in the cache, or zero if empty.
+func (c *TxCache) Height() uint32 {
+	if len(c.blocks) == 0 {
+		return 0
+	}
+	return c.blocks[len(c.blocks)-1].height
+}
+
+// Tail returns the height of the first block in the cache, or zero if empty.
+func (c *TxCache) Tail() uint32 {
+	if len(c.blocks) == 0 {
+		return 0
+	}
+	return c.blocks[0].height
+}
+
+// check returns an error if the block contains a cached transaction
+// or the same transaction twice.
+func (c *TxCache) check(block *wire.Block) error {
+	seen := make(map[[32]byte]struct{}, len(block.Body.Txs))
+	for i, hash := range blockTxHashes(block) {
+		if _, ok := seen[hash]; ok || c.Contains(&hash) {
+			return &InvalidTxError{Index: i, Err: ErrDuplicateTx}
+		}
+		seen[hash] = struct{}{}
+	}
+	return nil
+}
+
+// PushBlock adds the transactions of a new block on top of the cache.
+// The oldest block gets evicted once the cache exceeds the validity window.
+func (c *TxCache) PushBlock(block *wire.Block) {
+	c.blocks = append(c.blocks, c.add(block))
+	for len(c.blocks) > int(policy.TxValidityWindow) {
+		c.remove(&c.blocks[0])
+		c.blocks[0] = cachedBlock{}
+		c.blocks = c.blocks[1:]
+	}
+}
+
+// PrependBlock adds the transactions of a block preceding all cached blocks.
+// It does nothing if the cache already covers a full validity window.
+func (c *TxCache) PrependBlock(block *wire.Block) error {
+	if c.Missing() <= 0 {
+		return nil
+	}
+	if len(c.blocks) > 0 && block.Header.Height+1 != c.blocks[0].height {
+		return fmt.Errorf("cannot prepend block %d to cache starting at %d",
+			block.Header.Height, c.blocks[0].height)
+	}
+	c.blocks = append([]cachedBlock{c.add(block)}, c.blocks...)
+	return nil
+}
+
+// RevertBlock removes the transactions of the last block from the cache.
+func (c *TxCache) RevertBlock(block *wire.Block) error {
+	if height := c.Height(); len(c.blocks) == 0 || height != block.Header.Height {
+		return fmt.Errorf("cannot revert block %d from cache at %d",
+			block.Header.Height, height)
+	}
+	last := len(c.blocks) - 1
+	c.remove(&c.blocks[last])
+	c.blocks = c.blocks[:last]
+	return nil
+}
+
+func (c *TxCache) add(block *wire.Block) cachedBlock {
+	hashes := blockTxHashes(block)
+	for _, hash := range hashes {
+		c.txs[hash] = struct{}{}
+	}
+	return cachedBlock{height: block.Header.Height, hashes: hashes}
+}
+
+func (c *TxCache) remove(b *cachedBlock) {
+	for _, hash := range b.hashes {
+		delete(c.txs, hash)
+	}
+}
+
+func blockTxHashes(block *wire.Block) [][32]byte {
+	if block.Body == nil {
+		return nil
+	}
+	hashes := make([][32]byte, len(block.Body.Txs))
+	for i, tx := range block.Body.Txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
+}
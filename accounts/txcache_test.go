@@ -0,0 +1,90 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/policy"
+	"terorie.dev/nimiq/tree"
+	"terorie.dev/nimiq/wire"
+)
+
+func testTxBlock(height uint32, values ...uint64) *wire.Block {
+	block := &wire.Block{
+		Header: wire.BlockHeader{Height: height},
+		Body:   &wire.BlockBody{MinerAddr: [20]byte{0x02}},
+	}
+	for _, value := range values {
+		block.Body.Txs = append(block.Body.Txs, wire.WrapTx{Tx: &wire.ExtendedTx{
+			Sender:              [20]byte{0x01},
+			Recipient:           [20]byte{0x03},
+			Value:               value,
+			ValidityStartHeight: 1,
+			NetworkID:           4,
+		}})
+	}
+	return block
+}
+
+func TestTxCache(t *testing.T) {
+	cache := NewTxCache()
+	window := policy.TxValidityWindow
+	var blocks []*wire.Block
+	for h := uint32(1); h <= window+1; h++ {
+		block := testTxBlock(h, uint64(h))
+		blocks = append(blocks, block)
+		cache.PushBlock(block)
+	}
+	assert.Equal(t, 0, cache.Missing())
+	assert.Equal(t, window+1, cache.Height())
+	// The first block got evicted.
+	first := blocks[0].Body.Txs[0].Hash()
+	second := blocks[1].Body.Txs[0].Hash()
+	assert.False(t, cache.Contains(&first))
+	assert.True(t, cache.Contains(&second))
+
+	last := blocks[len(blocks)-1]
+	lastHash := last.Body.Txs[0].Hash()
+	assert.Error(t, cache.RevertBlock(blocks[0]))
+	require.NoError(t, cache.RevertBlock(last))
+	assert.False(t, cache.Contains(&lastHash))
+	assert.Equal(t, 1, cache.Missing())
+
+	assert.Error(t, cache.PrependBlock(blocks[1]))
+	require.NoError(t, cache.PrependBlock(blocks[0]))
+	assert.True(t, cache.Contains(&first))
+	assert.Equal(t, 0, cache.Missing())
+}
+
+func TestAccounts_DuplicateTx(t *testing.T) {
+	accounts := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accounts.NetworkID = 4
+	accounts.SkipVerify = true
+	accounts.TxCache = NewTxCache()
+	sender := [20]byte{0x01}
+	accounts.PutAccount(&sender, &wire.BasicAccount{Value: 1000})
+
+	block1 := testTxBlock(2, 10)
+	receipt, err := accounts.Push(block1)
+	require.NoError(t, err)
+
+	// Same transaction in the next block.
+	block2 := testTxBlock(3, 20, 10)
+	hashBefore := accounts.Tree.Hash()
+	_, err = accounts.Push(block2)
+	var invalid *InvalidTxError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, 1, invalid.Index)
+	assert.ErrorIs(t, err, ErrDuplicateTx)
+	assert.Equal(t, hashBefore, accounts.Tree.Hash())
+
+	// Same transaction twice in a block.
+	_, err = accounts.Push(testTxBlock(3, 20, 20))
+	assert.ErrorIs(t, err, ErrDuplicateTx)
+
+	// After reverting, the transaction can be included again.
+	require.NoError(t, accounts.Revert(block1, receipt))
+	_, err = accounts.Push(testTxBlock(2, 20, 10))
+	assert.NoError(t, err)
+}
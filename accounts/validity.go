@@ -0,0 +1,45 @@
+package accounts
+
+import (
+	"fmt"
+
+	"terorie.dev/nimiq/policy"
+	"terorie.dev/nimiq/wire"
+)
+
+// A ValidityWindowError means a transaction was included in a block
+// before its validity start height or after its validity window expired.
+type ValidityWindowError struct {
+	ValidityStartHeight, Height uint32
+}
+
+func (v *ValidityWindowError) Error() string {
+	return fmt.Sprintf("transaction valid from height %d included at height %d",
+		v.ValidityStartHeight, v.Height)
+}
+
+// CheckTxValidity checks whether the transaction can be included
+// in a block at the given height on the given network.
+//
+// Unlike wire.VerifyTx, the check is cheap and does not involve signatures.
+func CheckTxValidity(tx wire.Tx, networkID uint8, height uint32) error {
+	content := tx.AsTxContent()
+	if content.NetworkID != networkID {
+		return &wire.NetworkMismatchError{Expected: networkID, Got: content.NetworkID}
+	}
+	start := content.ValidityStartHeight
+	if height < start || uint64(height) >= uint64(start)+uint64(policy.TxValidityWindow) {
+		return &ValidityWindowError{ValidityStartHeight: start, Height: height}
+	}
+	return nil
+}
+
+// checkValidity runs CheckTxValidity on all transactions in a block.
+func (a *Accounts) checkValidity(block *wire.Block) error {
+	for i, tx := range block.Body.Txs {
+		if err := CheckTxValidity(tx.Tx, a.NetworkID, block.Header.Height); err != nil {
+			return &InvalidTxError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/tree"
+	"terorie.dev/nimiq/wire"
+)
+
+func TestCheckTxValidity(t *testing.T) {
+	tx := &wire.BasicTx{ValidityStartHeight: 1000, NetworkID: 4}
+	cases := []struct {
+		height uint32
+		valid  bool
+	}{
+		{height: 999, valid: false},
+		{height: 1000, valid: true},
+		{height: 1119, valid: true},
+		{height: 1120, valid: false},
+	}
+	for _, c := range cases {
+		err := CheckTxValidity(tx, 4, c.height)
+		if c.valid {
+			assert.NoError(t, err, "height %d", c.height)
+		} else {
+			var window *ValidityWindowError
+			assert.ErrorAs(t, err, &window, "height %d", c.height)
+		}
+	}
+
+	var mismatch *wire.NetworkMismatchError
+	require.ErrorAs(t, CheckTxValidity(tx, 42, 1000), &mismatch)
+	assert.Equal(t, uint8(42), mismatch.Expected)
+	assert.Equal(t, uint8(4), mismatch.Got)
+}
+
+func TestAccounts_ValidityWindow(t *testing.T) {
+	accounts := NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accounts.NetworkID = 4
+	// Signatures are not needed to test the validity window.
+	accounts.SkipVerify = true
+	sender := [20]byte{0x01}
+	accounts.PutAccount(&sender, &wire.BasicAccount{Value: 1000})
+
+	block := &wire.Block{
+		Header: wire.BlockHeader{Height: 200},
+		Body: &wire.BlockBody{
+			MinerAddr: [20]byte{0x02},
+			Txs: []wire.WrapTx{{Tx: &wire.ExtendedTx{
+				Sender:              sender,
+				Recipient:           [20]byte{0x03},
+				Value:               10,
+				ValidityStartHeight: 80,
+				NetworkID:           4,
+			}}},
+		},
+	}
+	_, err := accounts.Push(block)
+	var window *ValidityWindowError
+	require.ErrorAs(t, err, &window)
+	assert.Equal(t, uint32(80), window.ValidityStartHeight)
+
+	// Replays from other networks are rejected even without signature checks.
+	tx := block.Body.Txs[0].Tx.(*wire.ExtendedTx)
+	tx.ValidityStartHeight = 150
+	tx.NetworkID = 42
+	_, err = accounts.Push(block)
+	var mismatch *wire.NetworkMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+
+	tx.NetworkID = 4
+	_, err = accounts.Push(block)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,109 @@
+package accounts
+
+import (
+	"runtime"
+	"sync"
+
+	"terorie.dev/nimiq/wire"
+)
+
+// verifyTxs checks the network and signatures of all transactions in a block.
+func (a *Accounts) verifyTxs(block *wire.Block) error {
+	for i, tx := range block.Body.Txs {
+		if err := wire.VerifyTx(tx.Tx, a.NetworkID); err != nil {
+			return &InvalidTxError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// BatchVerifier checks transaction signatures on a pool of worker goroutines.
+//
+// Verifying is decoupled from applying blocks to the state:
+// Callers can queue upcoming blocks ahead of time,
+// and push them to an Accounts with SkipVerify set once their verification finished.
+type BatchVerifier struct {
+	networkID uint8
+	jobs      chan verifyJob
+	wg        sync.WaitGroup
+}
+
+type verifyJob struct {
+	tx     wire.Tx
+	index  int
+	result *Verification
+}
+
+// NewBatchVerifier starts workers verifying transactions for the given network.
+// If workers is zero or less, one worker per CPU is started.
+// The workers run until Close is called.
+func NewBatchVerifier(networkID uint8, workers int) *BatchVerifier {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	v := &BatchVerifier{
+		networkID: networkID,
+		jobs:      make(chan verifyJob, 4*workers),
+	}
+	v.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go v.work()
+	}
+	return v
+}
+
+func (v *BatchVerifier) work() {
+	defer v.wg.Done()
+	for job := range v.jobs {
+		if err := wire.VerifyTx(job.tx, v.networkID); err != nil {
+			job.result.fail(job.index, err)
+		}
+		job.result.pending.Done()
+	}
+}
+
+// Verify queues all transactions of a block for verification.
+// It blocks if the workers are busy.
+func (v *BatchVerifier) Verify(block *wire.Block) *Verification {
+	result := new(Verification)
+	if block.Body == nil {
+		return result
+	}
+	result.pending.Add(len(block.Body.Txs))
+	for i, tx := range block.Body.Txs {
+		v.jobs <- verifyJob{tx: tx.Tx, index: i, result: result}
+	}
+	return result
+}
+
+// Close stops the workers after all queued transactions have been verified.
+func (v *BatchVerifier) Close() {
+	close(v.jobs)
+	v.wg.Wait()
+}
+
+// Verification is the pending result of verifying the transactions of a block.
+type Verification struct {
+	pending sync.WaitGroup
+	mu      sync.Mutex
+	err     *InvalidTxError
+}
+
+func (r *Verification) fail(index int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Report the first invalid transaction, like the sequential check does.
+	if r.err == nil || index < r.err.Index {
+		r.err = &InvalidTxError{Index: index, Err: err}
+	}
+}
+
+// Wait blocks until all transactions of the block are verified.
+// It returns an *InvalidTxError for the first invalid transaction, if any.
+func (r *Verification) Wait() error {
+	r.pending.Wait()
+	if r.err == nil {
+		return nil
+	}
+	return r.err
+}
@@ -0,0 +1,41 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/wallet"
+	"terorie.dev/nimiq/wire"
+)
+
+func TestBatchVerifier(t *testing.T) {
+	verifier := NewBatchVerifier(4, 3)
+	defer verifier.Close()
+
+	w := wallet.GenerateBasic()
+	block := &wire.Block{Body: new(wire.BlockBody)}
+	for i := 0; i < 20; i++ {
+		tx := &wire.BasicTx{
+			SenderPubKey:        w.GetPublicKey(),
+			Value:               uint64(i),
+			ValidityStartHeight: 1,
+			NetworkID:           4,
+		}
+		require.NoError(t, w.SignBasicTx(tx))
+		block.Body.Txs = append(block.Body.Txs, wire.WrapTx{Tx: tx})
+	}
+	assert.NoError(t, verifier.Verify(block).Wait())
+
+	// Break some signatures.
+	block.Body.Txs[13].Tx.(*wire.BasicTx).Value++
+	block.Body.Txs[7].Tx.(*wire.BasicTx).Value++
+	var invalid *InvalidTxError
+	require.ErrorAs(t, verifier.Verify(block).Wait(), &invalid)
+	assert.Equal(t, 7, invalid.Index)
+	assert.ErrorIs(t, invalid, wire.ErrInvalidSignature)
+
+	// Matches the sequential check.
+	accounts := &Accounts{NetworkID: 4}
+	assert.Equal(t, invalid, accounts.verifyTxs(block))
+}
@@ -0,0 +1,306 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package argon2d implements the Argon2d memory-hard hash function (version 0x13).
+//
+// Nimiq uses Argon2d for proof-of-work and as an HTLC hash algorithm.
+// golang.org/x/crypto/argon2 only implements the Argon2i and Argon2id variants.
+//
+// Specification: https://www.rfc-editor.org/rfc/rfc9106.html
+//
+// This package is derived from golang.org/x/crypto/argon2 and keeps its
+// BSD license (see LICENSE in this directory). Changes from upstream:
+//   - the Argon2d type (0) is used in the initial hash,
+//   - processSegment takes the reference block index from the first word
+//     of the previous block (data-dependent addressing) instead of the
+//     Argon2i address generator,
+//   - lanes are processed sequentially instead of in goroutines,
+//   - the assembly BlaMka rounds are replaced by portable Go code.
+package argon2d
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Nimiq Argon2d parameters.
+const (
+	NimiqTime   = 1
+	NimiqMemory = 512 // KiB
+	NimiqSalt   = "nimiqrocks!"
+)
+
+// Sum computes the Nimiq flavor of Argon2d over data,
+// using a single lane and the Nimiq cost parameters and salt.
+func Sum(data []byte) (sum [32]byte) {
+	copy(sum[:], Key(data, []byte(NimiqSalt), NimiqTime, NimiqMemory, 1, 32))
+	return
+}
+
+// Key derives a key of keyLen bytes from the password and salt.
+// The memory parameter specifies the size of the memory in KiB.
+// The number of passes over the memory is specified by time.
+// Lanes are processed sequentially, the threads parameter only affects the output.
+func Key(password, salt []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	return deriveKey(password, salt, nil, nil, time, memory, threads, keyLen)
+}
+
+const (
+	version    = 0x13
+	typeD      = 0
+	blockWords = 128 // uint64 words per 1 KiB block
+	syncPoints = 4   // slices per pass
+)
+
+type block [blockWords]uint64
+
+func deriveKey(password, salt, secret, data []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	if time < 1 {
+		panic("argon2d: number of rounds too small")
+	}
+	if threads < 1 {
+		panic("argon2d: parallelism degree too low")
+	}
+	h0 := initHash(password, salt, secret, data, time, memory, uint32(threads), keyLen)
+
+	memory = memory / (syncPoints * uint32(threads)) * (syncPoints * uint32(threads))
+	if memory < 2*syncPoints*uint32(threads) {
+		memory = 2 * syncPoints * uint32(threads)
+	}
+	B := initBlocks(&h0, memory, uint32(threads))
+	processBlocks(B, time, memory, uint32(threads))
+	return extractKey(B, memory, uint32(threads), keyLen)
+}
+
+func initHash(password, salt, key, data []byte, time, memory, threads, keyLen uint32) [blake2b.Size + 8]byte {
+	var (
+		h0     [blake2b.Size + 8]byte
+		params [24]byte
+		tmp    [4]byte
+	)
+	b2, _ := blake2b.New512(nil)
+	binary.LittleEndian.PutUint32(params[0:4], threads)
+	binary.LittleEndian.PutUint32(params[4:8], keyLen)
+	binary.LittleEndian.PutUint32(params[8:12], memory)
+	binary.LittleEndian.PutUint32(params[12:16], time)
+	binary.LittleEndian.PutUint32(params[16:20], version)
+	binary.LittleEndian.PutUint32(params[20:24], typeD)
+	b2.Write(params[:])
+	for _, input := range [][]byte{password, salt, key, data} {
+		binary.LittleEndian.PutUint32(tmp[:], uint32(len(input)))
+		b2.Write(tmp[:])
+		b2.Write(input)
+	}
+	b2.Sum(h0[:0])
+	return h0
+}
+
+func initBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []block {
+	var buf [blockWords * 8]byte
+	B := make([]block, memory)
+	lanes := memory / threads
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * lanes
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+
+		binary.LittleEndian.PutUint32(h0[blake2b.Size:], 0)
+		blake2bHash(buf[:], h0[:])
+		for i := range B[j+0] {
+			B[j+0][i] = binary.LittleEndian.Uint64(buf[i*8:])
+		}
+
+		binary.LittleEndian.PutUint32(h0[blake2b.Size:], 1)
+		blake2bHash(buf[:], h0[:])
+		for i := range B[j+1] {
+			B[j+1][i] = binary.LittleEndian.Uint64(buf[i*8:])
+		}
+	}
+	return B
+}
+
+func processBlocks(B []block, time, memory, threads uint32) {
+	lanes := memory / threads
+	segments := lanes / syncPoints
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < syncPoints; slice++ {
+			// Segments of the same slice are independent,
+			// so processing them sequentially gives the same result.
+			for lane := uint32(0); lane < threads; lane++ {
+				processSegment(B, n, slice, lane, lanes, segments, threads)
+			}
+		}
+	}
+}
+
+func processSegment(B []block, n, slice, lane, lanes, segments, threads uint32) {
+	index := uint32(0)
+	if n == 0 && slice == 0 {
+		index = 2 // the first two blocks were generated by initBlocks
+	}
+	offset := lane*lanes + slice*segments + index
+	for index < segments {
+		prev := offset - 1
+		if index == 0 && slice == 0 {
+			prev += lanes // last block in lane
+		}
+		// Argon2d derives the reference block from the previous block (data-dependent).
+		random := B[prev][0]
+		ref := indexAlpha(random, lanes, segments, threads, n, slice, lane, index)
+		processBlockXOR(&B[offset], &B[prev], &B[ref])
+		index, offset = index+1, offset+1
+	}
+}
+
+func indexAlpha(rand uint64, lanes, segments, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(rand>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+	m, s := 3*segments, ((slice+1)%syncPoints)*segments
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segments, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+	return phi(rand, uint64(m), uint64(s), refLane, lanes)
+}
+
+func phi(rand, m, s uint64, lane, lanes uint32) uint32 {
+	p := rand & 0xFFFFFFFF
+	p = (p * p) >> 32
+	p = (p * m) >> 32
+	return lane*lanes + uint32((s+m-(p+1))%uint64(lanes))
+}
+
+// processBlockXOR runs the compression function G over in1 and in2
+// and XORs the result into out.
+// Blocks are zero-initialized, so during the first pass this is equivalent to overwriting.
+func processBlockXOR(out, in1, in2 *block) {
+	var t block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	// Apply the permutation to the rows of 16 words.
+	var v [16]uint64
+	for i := 0; i < blockWords; i += 16 {
+		copy(v[:], t[i:i+16])
+		permute(&v)
+		copy(t[i:i+16], v[:])
+	}
+	// Apply the permutation to the columns of 2-word registers.
+	for i := 0; i < blockWords/8; i += 2 {
+		for j := 0; j < 8; j++ {
+			v[2*j] = t[16*j+i]
+			v[2*j+1] = t[16*j+i+1]
+		}
+		permute(&v)
+		for j := 0; j < 8; j++ {
+			t[16*j+i] = v[2*j]
+			t[16*j+i+1] = v[2*j+1]
+		}
+	}
+	for i := range t {
+		out[i] ^= in1[i] ^ in2[i] ^ t[i]
+	}
+}
+
+// permute is the BLAKE2b round function with the BlaMka multiplication.
+func permute(v *[16]uint64) {
+	v[0], v[4], v[8], v[12] = mix(v[0], v[4], v[8], v[12])
+	v[1], v[5], v[9], v[13] = mix(v[1], v[5], v[9], v[13])
+	v[2], v[6], v[10], v[14] = mix(v[2], v[6], v[10], v[14])
+	v[3], v[7], v[11], v[15] = mix(v[3], v[7], v[11], v[15])
+	v[0], v[5], v[10], v[15] = mix(v[0], v[5], v[10], v[15])
+	v[1], v[6], v[11], v[12] = mix(v[1], v[6], v[11], v[12])
+	v[2], v[7], v[8], v[13] = mix(v[2], v[7], v[8], v[13])
+	v[3], v[4], v[9], v[14] = mix(v[3], v[4], v[9], v[14])
+}
+
+func mix(a, b, c, d uint64) (uint64, uint64, uint64, uint64) {
+	a = blaMka(a, b)
+	d = bits.RotateLeft64(d^a, -32)
+	c = blaMka(c, d)
+	b = bits.RotateLeft64(b^c, -24)
+	a = blaMka(a, b)
+	d = bits.RotateLeft64(d^a, -16)
+	c = blaMka(c, d)
+	b = bits.RotateLeft64(b^c, -63)
+	return a, b, c, d
+}
+
+func blaMka(x, y uint64) uint64 {
+	return x + y + 2*uint64(uint32(x))*uint64(uint32(y))
+}
+
+func extractKey(B []block, memory, threads, keyLen uint32) []byte {
+	lanes := memory / threads
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range B[(lane*lanes)+lanes-1] {
+			B[memory-1][i] ^= v
+		}
+	}
+
+	var block [blockWords * 8]byte
+	for i, v := range B[memory-1] {
+		binary.LittleEndian.PutUint64(block[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	blake2bHash(key, block[:])
+	return key
+}
+
+// blake2bHash is the variable-length hash function H' of Argon2.
+func blake2bHash(out []byte, in []byte) {
+	var b2 hashWriter
+	if n := len(out); n < blake2b.Size {
+		b2, _ = blake2b.New(n, nil)
+	} else {
+		b2, _ = blake2b.New512(nil)
+	}
+
+	var buffer [blake2b.Size]byte
+	binary.LittleEndian.PutUint32(buffer[:4], uint32(len(out)))
+	b2.Write(buffer[:4])
+	b2.Write(in)
+
+	if len(out) <= blake2b.Size {
+		b2.Sum(out[:0])
+		return
+	}
+
+	outLen := len(out)
+	b2.Sum(buffer[:0])
+	b2.Reset()
+	copy(out, buffer[:32])
+	out = out[32:]
+	for len(out) > blake2b.Size {
+		b2.Write(buffer[:])
+		b2.Sum(buffer[:0])
+		copy(out, buffer[:32])
+		out = out[32:]
+		b2.Reset()
+	}
+
+	if outLen%blake2b.Size > 0 { // outLen > 64
+		r := ((outLen + 31) / 32) - 2 // ⌈τ /32⌉-2
+		b2, _ = blake2b.New(outLen-32*r, nil)
+	}
+	b2.Write(buffer[:])
+	b2.Sum(out[:0])
+}
+
+type hashWriter interface {
+	Write([]byte) (int, error)
+	Sum([]byte) []byte
+	Reset()
+}
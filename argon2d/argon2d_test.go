@@ -0,0 +1,43 @@
+package argon2d
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveKey_RFC9106(t *testing.T) {
+	// Test vector from RFC 9106, section 5.1.
+	key := deriveKey(
+		bytes.Repeat([]byte{0x01}, 32),
+		bytes.Repeat([]byte{0x02}, 16),
+		bytes.Repeat([]byte{0x03}, 8),
+		bytes.Repeat([]byte{0x04}, 12),
+		3, 32, 4, 32)
+	assert.Equal(t,
+		"512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb",
+		hex.EncodeToString(key))
+}
+
+func TestSum(t *testing.T) {
+	// Header of the main net genesis block.
+	header, _ := hex.DecodeString("0001" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"7cda9a7fdf06655905ae5dbd9c535451471b078fa6f3df0e287e5b0fb47a573a" +
+		"1fefd44f1fa97185fda21e957545c97dc7643fa7e4efdd86e0aa4244d1e0bc5c" +
+		"1f010000" + "00000001" + "5ad23a98" + "000219d9")
+	pow := Sum(header)
+	assert.Equal(t,
+		"000087dccfcb8625a84c821887c5c515f92f5078501bf49369f8993657cdc034",
+		hex.EncodeToString(pow[:]))
+
+	a := Sum([]byte("nimiq"))
+	b := Sum([]byte("nimiq"))
+	c := Sum([]byte("nimiQ"))
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+	assert.Equal(t, Key([]byte("nimiq"), []byte(NimiqSalt), NimiqTime, NimiqMemory, 1, 32), a[:])
+}
@@ -20,6 +20,19 @@ func Size(v interface{}) (int, error) {
 
 func valueSize(v reflect.Value, ts tags) (total int, err error) {
 	kind := v.Kind()
+	// Check for SizeBESerial implementation.
+	var addr reflect.Value
+	if kind == reflect.Ptr {
+		addr = v
+	} else if v.CanAddr() {
+		addr = v.Addr()
+	}
+	if addr.IsValid() && addr.Type().NumMethod() > 0 && addr.CanInterface() {
+		if u, ok := addr.Interface().(Marshaler); ok {
+			return u.SizeBESerial()
+		}
+	}
+	// Generic size.
 	switch kind {
 	case reflect.Ptr:
 		if ts.optional {
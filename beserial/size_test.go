@@ -105,4 +105,14 @@ func TestSize(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, 4, z)
 	})
+	t.Run("Custom", func(t *testing.T) {
+		type s struct {
+			A uint8
+			B customMarshalTest
+			C uint8
+		}
+		z, err := Size(&s{})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, z)
+	})
 }
@@ -0,0 +1,452 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"terorie.dev/nimiq/accounts"
+	"terorie.dev/nimiq/policy"
+	"terorie.dev/nimiq/tree"
+	"terorie.dev/nimiq/wire"
+)
+
+// Block validation errors.
+var (
+	ErrOrphanBlock          = errors.New("predecessor of block unknown")
+	ErrInvalidSuccessor     = errors.New("block is not a valid successor of its predecessor")
+	ErrWrongDifficulty      = errors.New("block n-bits do not match difficulty adjustment")
+	ErrAccountsHashMismatch = errors.New("block accounts hash mismatch")
+	ErrForkTooDeep          = errors.New("fork branches off before the resumed chain head")
+)
+
+// PushResult describes the effect of pushing a block.
+type PushResult int
+
+// PushResult values.
+const (
+	// PushKnown means the block was already stored.
+	PushKnown PushResult = iota
+	// PushExtended means the block extended the main chain.
+	PushExtended
+	// PushRebranched means the block caused a reorg to a heavier fork.
+	PushRebranched
+	// PushForked means the block was stored on a fork lighter than the main chain.
+	PushForked
+)
+
+// ChainData is a block stored in the chain with its metadata.
+type ChainData struct {
+	Block           *wire.Block
+	Hash            [32]byte
+	TotalDifficulty *big.Rat
+	OnMainChain     bool
+	// Receipt undoes the block, set while the block is on the main chain.
+	Receipt *accounts.Receipt
+
+	pow *[32]byte // proof-of-work hash, computed once when needed
+}
+
+// powHash returns the proof-of-work hash of the block.
+func (d *ChainData) powHash() *[32]byte {
+	if d.pow == nil {
+		pow := d.Block.Header.PowHash()
+		d.pow = &pow
+	}
+	return d.pow
+}
+
+// HeadChange is emitted when the head of the main chain changes.
+type HeadChange struct {
+	Head *ChainData
+	// Reverted lists blocks removed from the main chain, newest first.
+	Reverted []*ChainData
+	// Pushed lists blocks added to the main chain, oldest first.
+	Pushed []*ChainData
+}
+
+// Chain stores blocks in a tree rooted at the genesis block
+// and follows the fork with the highest total difficulty.
+//
+// The accounts are kept at the state of the head block.
+// Switching to a heavier fork reverts the main chain to the fork point,
+// using the receipts of the reverted blocks, and pushes the blocks of the fork.
+type Chain struct {
+	// SkipPoW disables proof-of-work checks,
+	// for trusted block sources and tests.
+	// Interlinks are built from proof-of-work hashes,
+	// so they are only checked against the interlink hash.
+	SkipPoW bool
+
+	mu          sync.Mutex
+	accounts    *accounts.Accounts
+	archive     *tree.Archive
+	genesisHash [32]byte
+	base        uint32 // height below which blocks cannot be reverted
+	blocks      map[[32]byte]*ChainData
+	main        []*ChainData // main chain indexed by height - 1
+	listeners   []func(*HeadChange)
+}
+
+// NewChain creates a chain starting at the genesis block.
+// The accounts must hold the state after the genesis block.
+func NewChain(accs *accounts.Accounts, genesis *wire.Block) (*Chain, error) {
+	if genesis.Header.Height != 1 {
+		return nil, fmt.Errorf("genesis block at height %d", genesis.Header.Height)
+	}
+	if hash := accs.Tree.Hash(); hash != genesis.Header.AccountsHash {
+		return nil, fmt.Errorf("%w: %x vs %x", ErrAccountsHashMismatch,
+			hash, genesis.Header.AccountsHash)
+	}
+	data := &ChainData{
+		Block:           genesis,
+		Hash:            genesis.Header.Hash(),
+		TotalDifficulty: Difficulty(genesis.Header.NBits),
+		OnMainChain:     true,
+	}
+	return &Chain{
+		accounts:    accs,
+		genesisHash: data.Hash,
+		base:        1,
+		blocks:      map[[32]byte]*ChainData{data.Hash: data},
+		main:        []*ChainData{data},
+	}, nil
+}
+
+// ResumeChain creates a chain from the main chain blocks up to a stored head,
+// starting with the genesis block.
+// The accounts must hold the state after the last block, e.g. from a persistent tree.
+// The blocks are only checked to be linked and are not applied again.
+// Without receipts they cannot be reverted, so forks branching off below the head are rejected.
+func ResumeChain(accs *accounts.Accounts, blocks []*wire.Block) (*Chain, error) {
+	if len(blocks) == 0 || blocks[0].Header.Height != 1 {
+		return nil, errors.New("resumed chain does not start at the genesis block")
+	}
+	head := blocks[len(blocks)-1]
+	if hash := accs.Tree.Hash(); hash != head.Header.AccountsHash {
+		return nil, fmt.Errorf("%w: %x vs %x", ErrAccountsHashMismatch,
+			hash, head.Header.AccountsHash)
+	}
+	c := &Chain{
+		accounts: accs,
+		base:     head.Header.Height,
+		blocks:   make(map[[32]byte]*ChainData, len(blocks)),
+		main:     make([]*ChainData, 0, len(blocks)),
+	}
+	totalDifficulty := new(big.Rat)
+	for i, block := range blocks {
+		if i > 0 {
+			prev := c.main[i-1]
+			if block.Header.Height != prev.Block.Header.Height+1 || block.Header.PrevHash != prev.Hash {
+				return nil, fmt.Errorf("%w: block %d", ErrInvalidSuccessor, block.Header.Height)
+			}
+		}
+		data := &ChainData{
+			Block:           block,
+			Hash:            block.Header.Hash(),
+			TotalDifficulty: new(big.Rat).Add(totalDifficulty, Difficulty(block.Header.NBits)),
+			OnMainChain:     true,
+		}
+		totalDifficulty = data.TotalDifficulty
+		c.blocks[data.Hash] = data
+		c.main = append(c.main, data)
+	}
+	c.genesisHash = c.main[0].Hash
+	c.refillTxCache()
+	return c, nil
+}
+
+// OnHeadChanged registers a function called after the head changes.
+// It is called without holding locks on the chain.
+func (c *Chain) OnHeadChanged(fn func(*HeadChange)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, fn)
+}
+
+// Head returns the last block of the main chain.
+func (c *Chain) Head() *ChainData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.head()
+}
+
+func (c *Chain) head() *ChainData {
+	return c.main[len(c.main)-1]
+}
+
+// GetBlock looks up a stored block by hash.
+func (c *Chain) GetBlock(hash *[32]byte) *ChainData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blocks[*hash]
+}
+
+// GetBlockAt returns the block of the main chain at the given height.
+func (c *Chain) GetBlockAt(height uint32) *ChainData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if height < 1 || int(height) > len(c.main) {
+		return nil
+	}
+	return c.main[height-1]
+}
+
+// SetArchive sets an archive keeping a snapshot of the accounts
+// after each block applied to the main chain.
+// With a BoltStore, the snapshots are written on its next commit.
+func (c *Chain) SetArchive(archive *tree.Archive) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.archive = archive
+}
+
+// AccountsAt returns a read-only view of the accounts
+// after the main chain block at the given height.
+// The state must have been retained by the archive.
+func (c *Chain) AccountsAt(height uint32) (*accounts.Accounts, error) {
+	c.mu.Lock()
+	archive := c.archive
+	c.mu.Unlock()
+	if archive == nil {
+		return nil, tree.ErrSnapshotNotFound
+	}
+	view, err := archive.View(height)
+	if err != nil {
+		return nil, err
+	}
+	return accounts.NewAccounts(view), nil
+}
+
+// Push validates a block and adds it to the chain.
+// Blocks extending the main chain or making a fork the heaviest chain get applied to the accounts.
+func (c *Chain) Push(block *wire.Block) (PushResult, error) {
+	c.mu.Lock()
+	result, change, err := c.push(block)
+	listeners := c.listeners
+	c.mu.Unlock()
+	if change != nil {
+		for _, fn := range listeners {
+			fn(change)
+		}
+	}
+	return result, err
+}
+
+func (c *Chain) push(block *wire.Block) (PushResult, *HeadChange, error) {
+	hash := block.Header.Hash()
+	if _, ok := c.blocks[hash]; ok {
+		return PushKnown, nil, nil
+	}
+	prev, pow, err := c.verify(block)
+	if err != nil {
+		return 0, nil, err
+	}
+	data := &ChainData{
+		Block:           block,
+		Hash:            hash,
+		TotalDifficulty: new(big.Rat).Add(prev.TotalDifficulty, Difficulty(block.Header.NBits)),
+		pow:             pow,
+	}
+
+	// Extend the main chain.
+	if prev == c.head() {
+		if err := c.apply(data); err != nil {
+			return 0, nil, err
+		}
+		c.blocks[hash] = data
+		return PushExtended, &HeadChange{Head: data, Pushed: []*ChainData{data}}, nil
+	}
+
+	// Store forks, switching to them once they become heavier.
+	c.blocks[hash] = data
+	if data.TotalDifficulty.Cmp(c.head().TotalDifficulty) <= 0 {
+		return PushForked, nil, nil
+	}
+	change, err := c.rebranch(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return PushRebranched, change, nil
+}
+
+// verify checks a block against its predecessor and returns the predecessor
+// and the proof-of-work hash of the block, unless proof-of-work checks are skipped.
+// Cheap checks come first to reject invalid blocks before hashing with Argon2d.
+func (c *Chain) verify(block *wire.Block) (*ChainData, *[32]byte, error) {
+	header := &block.Header
+	if err := block.VerifyBodyHash(); err != nil {
+		return nil, nil, err
+	}
+	if err := block.VerifyInterlinkHash(&c.genesisHash); err != nil {
+		return nil, nil, err
+	}
+	prev, ok := c.blocks[header.PrevHash]
+	if !ok {
+		return nil, nil, ErrOrphanBlock
+	}
+	prevHeader := &prev.Block.Header
+	if header.Height != prevHeader.Height+1 || header.Timestamp < prevHeader.Timestamp {
+		return nil, nil, ErrInvalidSuccessor
+	}
+	nBits, err := c.nextNBits(prev)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.NBits != nBits {
+		return nil, nil, fmt.Errorf("%w: %08x, expected %08x", ErrWrongDifficulty, header.NBits, nBits)
+	}
+	if c.SkipPoW {
+		return prev, nil, nil
+	}
+	pow := header.PowHash()
+	if err := header.VerifyPowHash(&pow); err != nil {
+		return nil, nil, err
+	}
+	if err := block.VerifyInterlink(prev.Block, prev.powHash()); err != nil {
+		return nil, nil, err
+	}
+	return prev, &pow, nil
+}
+
+// nextNBits computes the n-bits of the block following head, which may be on a fork.
+func (c *Chain) nextNBits(head *ChainData) (uint32, error) {
+	window := policy.DifficultyBlockWindow
+	tailHeight := uint32(1)
+	if head.Block.Header.Height > window {
+		tailHeight = head.Block.Header.Height - window
+	}
+	tail := head
+	for tail.Block.Header.Height > tailHeight {
+		if tail.OnMainChain {
+			tail = c.main[tailHeight-1]
+			break
+		}
+		prev, ok := c.blocks[tail.Block.Header.PrevHash]
+		if !ok {
+			return 0, ErrOrphanBlock
+		}
+		tail = prev
+	}
+	delta := new(big.Rat).Sub(head.TotalDifficulty, tail.TotalDifficulty)
+	return NextNBits(&head.Block.Header, &tail.Block.Header, delta)
+}
+
+// apply pushes a block on top of the main chain.
+func (c *Chain) apply(data *ChainData) error {
+	receipt, err := c.accounts.Push(data.Block)
+	if err != nil {
+		return err
+	}
+	if hash := c.accounts.Tree.Hash(); hash != data.Block.Header.AccountsHash {
+		if err := c.accounts.Revert(data.Block, receipt); err != nil {
+			panic("failed to revert block: " + err.Error())
+		}
+		return fmt.Errorf("%w: %x vs %x", ErrAccountsHashMismatch,
+			hash, data.Block.Header.AccountsHash)
+	}
+	data.Receipt = receipt
+	data.OnMainChain = true
+	c.main = append(c.main, data)
+	if c.archive != nil {
+		c.archive.Snapshot(data.Block.Header.Height, c.accounts.Tree)
+	}
+	return nil
+}
+
+// unapply reverts the head of the main chain.
+func (c *Chain) unapply() *ChainData {
+	data := c.head()
+	if err := c.accounts.Revert(data.Block, data.Receipt); err != nil {
+		panic("failed to revert block: " + err.Error())
+	}
+	data.Receipt = nil
+	data.OnMainChain = false
+	c.main = c.main[:len(c.main)-1]
+	if c.archive != nil {
+		c.archive.Release(data.Block.Header.Height)
+	}
+	return data
+}
+
+// rebranch switches the main chain to the fork ending with the given block.
+// If a block of the fork turns out invalid, the previous main chain is restored
+// and the invalid block and all of its descendants are removed.
+func (c *Chain) rebranch(head *ChainData) (*HeadChange, error) {
+	// Collect fork blocks down to the fork point.
+	var fork []*ChainData
+	for data := head; !data.OnMainChain; {
+		fork = append(fork, data)
+		prev, ok := c.blocks[data.Block.Header.PrevHash]
+		if !ok {
+			c.remove(fork[len(fork)-1])
+			return nil, ErrOrphanBlock
+		}
+		data = prev
+	}
+	forkPoint := fork[len(fork)-1].Block.Header.Height - 1
+	if forkPoint < c.base {
+		c.remove(fork[len(fork)-1])
+		return nil, ErrForkTooDeep
+	}
+
+	change := &HeadChange{Head: head}
+	for c.head().Block.Header.Height > forkPoint {
+		change.Reverted = append(change.Reverted, c.unapply())
+	}
+	c.refillTxCache()
+	for i := len(fork) - 1; i >= 0; i-- {
+		if err := c.apply(fork[i]); err != nil {
+			c.remove(fork[i])
+			// Restore the previous main chain.
+			for c.head().Block.Header.Height > forkPoint {
+				c.unapply()
+			}
+			c.refillTxCache()
+			for j := len(change.Reverted) - 1; j >= 0; j-- {
+				if err := c.apply(change.Reverted[j]); err != nil {
+					panic("failed to restore main chain: " + err.Error())
+				}
+			}
+			return nil, err
+		}
+		change.Pushed = append(change.Pushed, fork[i])
+	}
+	return change, nil
+}
+
+// remove deletes a block off the main chain together with all of its descendants,
+// so that no stored block refers to a missing predecessor.
+func (c *Chain) remove(data *ChainData) {
+	removed := map[[32]byte]bool{data.Hash: true}
+	delete(c.blocks, data.Hash)
+	for found := true; found; {
+		found = false
+		for hash, child := range c.blocks {
+			if removed[child.Block.Header.PrevHash] {
+				removed[hash] = true
+				delete(c.blocks, hash)
+				found = true
+			}
+		}
+	}
+}
+
+// refillTxCache adds main chain blocks that fell out of the transaction cache
+// after reverting blocks.
+func (c *Chain) refillTxCache() {
+	cache := c.accounts.TxCache
+	if cache == nil {
+		return
+	}
+	next := c.head().Block.Header.Height
+	if tail := cache.Tail(); tail != 0 {
+		next = tail - 1
+	}
+	for ; cache.Missing() > 0 && next >= 1; next-- {
+		if err := cache.PrependBlock(c.main[next-1].Block); err != nil {
+			panic("failed to refill transaction cache: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/accounts"
+	"terorie.dev/nimiq/tree"
+	"terorie.dev/nimiq/wire"
+)
+
+// testBuilder mines blocks without proof-of-work.
+type testBuilder struct {
+	t       *testing.T
+	genesis *wire.Block
+	parents map[[32]byte]*wire.Block
+}
+
+func newTestBuilder(t *testing.T) *testBuilder {
+	genesis := &wire.Block{
+		Header: wire.BlockHeader{
+			Version:   wire.BlockVersion1,
+			NBits:     0x1f010000,
+			Height:    1,
+			Timestamp: 1_500_000_000,
+		},
+		Body: &wire.BlockBody{MinerAddr: [20]byte{0x01}},
+	}
+	genesis.Header.BodyHash = genesis.Body.Hash()
+	b := &testBuilder{t: t, genesis: genesis, parents: make(map[[32]byte]*wire.Block)}
+	genesis.Header.AccountsHash = b.accounts(genesis).Tree.Hash()
+	b.parents[genesis.Header.Hash()] = genesis
+	return b
+}
+
+// branch returns the blocks from genesis up to the given block.
+func (b *testBuilder) branch(block *wire.Block) []*wire.Block {
+	var blocks []*wire.Block
+	for block.Header.Height > 1 {
+		blocks = append([]*wire.Block{block}, blocks...)
+		block = b.parents[block.Header.PrevHash]
+	}
+	return append([]*wire.Block{b.genesis}, blocks...)
+}
+
+// accounts returns the state after the given block.
+func (b *testBuilder) accounts(block *wire.Block) *accounts.Accounts {
+	accs := accounts.NewAccounts(&tree.PMTree{Store: tree.NewMemStore()})
+	accs.TxCache = accounts.NewTxCache()
+	blocks := []*wire.Block{b.genesis}
+	if block != b.genesis {
+		blocks = b.branch(block)
+	}
+	for _, block := range blocks {
+		_, err := accs.Push(block)
+		require.NoError(b.t, err)
+	}
+	return accs
+}
+
+// next builds a valid block on top of parent.
+func (b *testBuilder) next(parent *wire.Block, miner byte) *wire.Block {
+	var headers []*wire.BlockHeader
+	for _, block := range b.branch(parent) {
+		headers = append(headers, &block.Header)
+	}
+	if len(headers) > 121 {
+		headers = headers[len(headers)-121:]
+	}
+	nBits, err := NextNBitsFromHeaders(headers)
+	require.NoError(b.t, err)
+	block := &wire.Block{
+		Header: wire.BlockHeader{
+			Version:   wire.BlockVersion1,
+			PrevHash:  parent.Header.Hash(),
+			NBits:     nBits,
+			Height:    parent.Header.Height + 1,
+			Timestamp: parent.Header.Timestamp + 60,
+		},
+		Body: &wire.BlockBody{MinerAddr: [20]byte{miner}},
+	}
+	parentPow := parent.Header.PowHash()
+	block.Interlink = *parent.NextInterlink(&parentPow, block.Header.Target())
+	genesisHash := b.genesis.Header.Hash()
+	block.Header.InterlinkHash = block.Interlink.Hash(&genesisHash)
+	block.Header.BodyHash = block.Body.Hash()
+	block.Header.AccountsHash = b.accounts(block).Tree.Hash()
+	b.parents[block.Header.Hash()] = block
+	return block
+}
+
+func TestChain(t *testing.T) {
+	b := newTestBuilder(t)
+	accs := b.accounts(b.genesis)
+	chain, err := NewChain(accs, b.genesis)
+	require.NoError(t, err)
+	chain.SkipPoW = true
+	var changes []*HeadChange
+	chain.OnHeadChanged(func(change *HeadChange) {
+		changes = append(changes, change)
+	})
+
+	// Extend the main chain.
+	b2 := b.next(b.genesis, 0x02)
+	b3 := b.next(b2, 0x02)
+	for _, block := range []*wire.Block{b2, b3} {
+		result, err := chain.Push(block)
+		require.NoError(t, err)
+		assert.Equal(t, PushExtended, result)
+	}
+	assert.Equal(t, b3, chain.Head().Block)
+	assert.Equal(t, b2, chain.GetBlockAt(2).Block)
+	require.Len(t, changes, 2)
+	result, err := chain.Push(b3)
+	require.NoError(t, err)
+	assert.Equal(t, PushKnown, result)
+
+	// A fork of equal weight does not replace the main chain.
+	c3 := b.next(b2, 0x03)
+	result, err = chain.Push(c3)
+	require.NoError(t, err)
+	assert.Equal(t, PushForked, result)
+	assert.Equal(t, b3, chain.Head().Block)
+	assert.Equal(t, b3.Header.AccountsHash, accs.Tree.Hash())
+
+	// The fork becomes heavier.
+	c4 := b.next(c3, 0x03)
+	result, err = chain.Push(c4)
+	require.NoError(t, err)
+	assert.Equal(t, PushRebranched, result)
+	assert.Equal(t, c4, chain.Head().Block)
+	assert.Equal(t, c4.Header.AccountsHash, accs.Tree.Hash())
+	b3Hash := b3.Header.Hash()
+	assert.False(t, chain.GetBlock(&b3Hash).OnMainChain)
+	require.Len(t, changes, 3)
+	change := changes[2]
+	require.Len(t, change.Reverted, 1)
+	assert.Equal(t, b3, change.Reverted[0].Block)
+	require.Len(t, change.Pushed, 2)
+	assert.Equal(t, c3, change.Pushed[0].Block)
+	assert.Equal(t, c4, change.Pushed[1].Block)
+	// The transaction cache follows the main chain.
+	assert.Equal(t, uint32(1), accs.TxCache.Tail())
+	assert.Equal(t, uint32(4), accs.TxCache.Height())
+
+	// The old branch takes over again.
+	b4 := b.next(b3, 0x02)
+	b5 := b.next(b4, 0x02)
+	_, err = chain.Push(b4)
+	require.NoError(t, err)
+	result, err = chain.Push(b5)
+	require.NoError(t, err)
+	assert.Equal(t, PushRebranched, result)
+	assert.Equal(t, b5.Header.AccountsHash, accs.Tree.Hash())
+	assert.False(t, chain.GetBlock(&c4.Header.PrevHash).OnMainChain)
+}
+
+func TestChain_Invalid(t *testing.T) {
+	b := newTestBuilder(t)
+	accs := b.accounts(b.genesis)
+	chain, err := NewChain(accs, b.genesis)
+	require.NoError(t, err)
+	chain.SkipPoW = true
+
+	b2 := b.next(b.genesis, 0x02)
+	b3 := b.next(b2, 0x02)
+	_, err = chain.Push(b3)
+	assert.ErrorIs(t, err, ErrOrphanBlock)
+	_, err = chain.Push(b2)
+	require.NoError(t, err)
+
+	wrongDifficulty := *b3
+	wrongDifficulty.Header.NBits = 0x1f008000
+	_, err = chain.Push(&wrongDifficulty)
+	assert.ErrorIs(t, err, ErrWrongDifficulty)
+
+	wrongBody := *b3
+	wrongBody.Body = &wire.BlockBody{MinerAddr: [20]byte{0x09}}
+	_, err = chain.Push(&wrongBody)
+	assert.ErrorIs(t, err, wire.ErrBodyHashMismatch)
+
+	wrongAccounts := *b3
+	wrongAccounts.Header.AccountsHash = [32]byte{0x01}
+	_, err = chain.Push(&wrongAccounts)
+	assert.ErrorIs(t, err, ErrAccountsHashMismatch)
+	assert.Equal(t, b2.Header.AccountsHash, accs.Tree.Hash())
+
+	// An invalid block on a heavier fork restores the main chain.
+	_, err = chain.Push(b3)
+	require.NoError(t, err)
+	c3 := b.next(b2, 0x03)
+	c4 := *b.next(c3, 0x03)
+	c4.Header.AccountsHash = [32]byte{0x01}
+	_, err = chain.Push(c3)
+	require.NoError(t, err)
+	_, err = chain.Push(&c4)
+	assert.ErrorIs(t, err, ErrAccountsHashMismatch)
+	assert.Equal(t, b3, chain.Head().Block)
+	assert.Equal(t, b3.Header.AccountsHash, accs.Tree.Hash())
+	c4Hash := c4.Header.Hash()
+	assert.Nil(t, chain.GetBlock(&c4Hash))
+	assert.False(t, chain.GetBlock(&c4.Header.PrevHash).OnMainChain)
+
+	// An invalid fork block takes all of its descendants with it.
+	b4 := b.next(b3, 0x02)
+	_, err = chain.Push(b4)
+	require.NoError(t, err)
+	d3 := *b.next(b2, 0x04)
+	d3.Header.AccountsHash = [32]byte{0x01}
+	b.parents[d3.Header.Hash()] = &d3
+	d4 := b.next(&d3, 0x04)
+	e4 := b.next(&d3, 0x05)
+	d5 := b.next(d4, 0x04)
+	e5 := b.next(e4, 0x05)
+	for _, block := range []*wire.Block{&d3, d4, e4} {
+		result, err := chain.Push(block)
+		require.NoError(t, err)
+		assert.Equal(t, PushForked, result)
+	}
+	_, err = chain.Push(d5)
+	assert.ErrorIs(t, err, ErrAccountsHashMismatch)
+	assert.Equal(t, b4, chain.Head().Block)
+	for _, block := range []*wire.Block{&d3, d4, e4, d5} {
+		hash := block.Header.Hash()
+		assert.Nil(t, chain.GetBlock(&hash), "block %x", hash)
+	}
+	_, err = chain.Push(e5)
+	assert.ErrorIs(t, err, ErrOrphanBlock)
+}
+
+func TestResumeChain(t *testing.T) {
+	b := newTestBuilder(t)
+	b2 := b.next(b.genesis, 0x02)
+	b3 := b.next(b2, 0x02)
+
+	_, err := ResumeChain(b.accounts(b2), b.branch(b3))
+	assert.ErrorIs(t, err, ErrAccountsHashMismatch)
+	_, err = ResumeChain(b.accounts(b3), []*wire.Block{b.genesis, b3})
+	assert.ErrorIs(t, err, ErrInvalidSuccessor)
+
+	accs := b.accounts(b3)
+	accs.TxCache = accounts.NewTxCache()
+	chain, err := ResumeChain(accs, b.branch(b3))
+	require.NoError(t, err)
+	chain.SkipPoW = true
+	assert.Equal(t, b3, chain.Head().Block)
+	assert.Equal(t, b2, chain.GetBlockAt(2).Block)
+	assert.Equal(t, uint32(3), accs.TxCache.Height())
+	assert.Equal(t, uint32(1), accs.TxCache.Tail())
+
+	// Blocks extend the resumed chain.
+	b4 := b.next(b3, 0x02)
+	result, err := chain.Push(b4)
+	require.NoError(t, err)
+	assert.Equal(t, PushExtended, result)
+
+	// The resumed blocks cannot be reverted.
+	c3 := b.next(b2, 0x03)
+	c4 := b.next(c3, 0x03)
+	c5 := b.next(c4, 0x03)
+	for _, block := range []*wire.Block{c3, c4} {
+		result, err = chain.Push(block)
+		require.NoError(t, err)
+		assert.Equal(t, PushForked, result)
+	}
+	_, err = chain.Push(c5)
+	assert.ErrorIs(t, err, ErrForkTooDeep)
+	assert.Equal(t, b4, chain.Head().Block)
+	assert.Equal(t, b4.Header.AccountsHash, accs.Tree.Hash())
+	assert.Nil(t, chain.GetBlock(&c4.Header.PrevHash))
+}
+
+func TestChain_Archive(t *testing.T) {
+	b := newTestBuilder(t)
+	accs := b.accounts(b.genesis)
+	chain, err := NewChain(accs, b.genesis)
+	require.NoError(t, err)
+	chain.SkipPoW = true
+	archive := tree.NewArchive(accs.Tree.Store.(*tree.MemStore))
+	archive.Retention = 3
+	chain.SetArchive(archive)
+
+	b2 := b.next(b.genesis, 0x02)
+	b3 := b.next(b2, 0x02)
+	c3 := b.next(b2, 0x03)
+	c4 := b.next(c3, 0x03)
+	for _, block := range []*wire.Block{b2, b3, c3, c4} {
+		_, err := chain.Push(block)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []uint32{2, 3, 4}, archive.Heights())
+	for _, block := range []*wire.Block{b2, c3, c4} {
+		historic, err := chain.AccountsAt(block.Header.Height)
+		require.NoError(t, err)
+		assert.Equal(t, block.Header.AccountsHash, historic.Tree.Hash())
+	}
+	// Query a balance in the past.
+	historic, err := chain.AccountsAt(2)
+	require.NoError(t, err)
+	assert.True(t, historic.GetAccount(&[20]byte{0x03}).IsEmpty())
+	assert.False(t, accs.GetAccount(&[20]byte{0x03}).IsEmpty())
+
+	_, err = chain.AccountsAt(1)
+	assert.ErrorIs(t, err, tree.ErrSnapshotNotFound)
+
+	// Older snapshots are released past the retention.
+	c5 := b.next(c4, 0x03)
+	_, err = chain.Push(c5)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{3, 4, 5}, archive.Heights())
+}
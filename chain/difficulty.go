@@ -0,0 +1,129 @@
+package chain
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"terorie.dev/nimiq/policy"
+	"terorie.dev/nimiq/wire"
+)
+
+// The reference implementation computes difficulties and targets with bignumber.js,
+// which rounds divisions to 20 decimal places (half up).
+// Other operations are exact, so rationals reproduce its results.
+const decimalPlaces = 20
+
+var decimalScale = new(big.Int).Exp(big.NewInt(10), big.NewInt(decimalPlaces), nil)
+
+// div divides a by b, rounding the result like bignumber.js.
+// Both numbers must be positive.
+func div(a, b *big.Rat) *big.Rat {
+	num := new(big.Int).Mul(a.Num(), b.Denom())
+	num.Mul(num, decimalScale)
+	denom := new(big.Int).Mul(a.Denom(), b.Num())
+	quo, rem := new(big.Int).QuoRem(num, denom, new(big.Int))
+	// Round half up.
+	if rem.Lsh(rem, 1).Cmp(denom) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	return new(big.Rat).SetFrac(quo, decimalScale)
+}
+
+func targetMax() *big.Rat {
+	return new(big.Rat).SetInt(&policy.BlockTargetMax)
+}
+
+// Difficulty returns the difficulty of a block with the given n-bits,
+// defined as the maximum target divided by the block target.
+// The total difficulty of a chain is the sum of its block difficulties.
+func Difficulty(nBits uint32) *big.Rat {
+	target := wire.CompactToTarget(nBits)
+	if target.Sign() <= 0 {
+		return new(big.Rat)
+	}
+	return div(targetMax(), new(big.Rat).SetInt(&target))
+}
+
+// NextNBits computes the n-bits of the block following head.
+//
+// The tail is the block DifficultyBlockWindow blocks before head,
+// or the block at height 1 for the first blocks of the chain.
+// deltaTotalDifficulty is the sum of difficulties of the blocks after tail up to head.
+func NextNBits(head, tail *wire.BlockHeader, deltaTotalDifficulty *big.Rat) (uint32, error) {
+	window := policy.DifficultyBlockWindow
+	if head.Height-tail.Height != window && !(head.Height <= window && tail.Height == 1) {
+		return 0, fmt.Errorf("tail %d and head %d must be %d blocks apart",
+			tail.Height, head.Height, window)
+	}
+
+	actualTime := float64(int64(head.Timestamp) - int64(tail.Timestamp))
+	delta := new(big.Rat).Set(deltaTotalDifficulty)
+
+	// Simulate that the block time was achieved for the blocks before the genesis block,
+	// i.e. a sliding window that starts before the genesis block with difficulty 1.
+	if head.Height <= window {
+		missing := window - head.Height + 1
+		actualTime += float64(missing * policy.BlockTime)
+		delta.Add(delta, new(big.Rat).SetInt64(int64(missing)))
+	}
+
+	// Compute the target adjustment factor.
+	expectedTime := float64(window * policy.BlockTime)
+	adjustment := actualTime / expectedTime
+	adjustment = math.Max(adjustment, 1/policy.DifficultyMaxAdjustmentFactor)
+	adjustment = math.Min(adjustment, policy.DifficultyMaxAdjustmentFactor)
+	// bignumber.js converts numbers using their shortest string representation.
+	adjustmentRat, _ := new(big.Rat).SetString(strconv.FormatFloat(adjustment, 'g', -1, 64))
+
+	// Compute the next target.
+	averageDifficulty := div(delta, new(big.Rat).SetInt64(int64(window)))
+	if averageDifficulty.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid total difficulty: %s", deltaTotalDifficulty)
+	}
+	averageTarget := div(targetMax(), averageDifficulty)
+	nextTarget := new(big.Rat).Mul(averageTarget, adjustmentRat)
+
+	// Make sure the target is below or equal the maximum allowed target (difficulty 1).
+	// Also enforce a minimum target of 1.
+	if nextTarget.Cmp(targetMax()) > 0 {
+		nextTarget = targetMax()
+	}
+	if nextTarget.Cmp(big.NewRat(1, 1)) < 0 {
+		nextTarget = big.NewRat(1, 1)
+	}
+	return targetToCompact(nextTarget), nil
+}
+
+// NextNBitsFromHeaders computes the n-bits of the block following the last header.
+// The headers must be consecutive, starting with the tail block as defined in NextNBits.
+func NextNBitsFromHeaders(headers []*wire.BlockHeader) (uint32, error) {
+	if len(headers) == 0 {
+		return 0, fmt.Errorf("no headers")
+	}
+	delta := new(big.Rat)
+	for i := 1; i < len(headers); i++ {
+		if headers[i].Height != headers[i-1].Height+1 {
+			return 0, fmt.Errorf("headers not consecutive at height %d", headers[i].Height)
+		}
+		delta.Add(delta, Difficulty(headers[i].NBits))
+	}
+	return NextNBits(headers[len(headers)-1], headers[0], delta)
+}
+
+// targetToCompact converts a fractional target into the compact "n-bits" representation.
+// It mirrors the floating point arithmetic of the reference implementation,
+// which truncates the target to the precision of a float64.
+func targetToCompact(target *big.Rat) uint32 {
+	f, _ := target.Float64()
+	size := int(math.Max(math.Ceil(math.Log2(f)/8), 1))
+	firstByte := math.Ldexp(f, -(size-1)*8)
+	// If the first (most significant) byte is greater than 127 (0x7f),
+	// prepend a zero byte.
+	if firstByte >= 0x80 && size >= 3 {
+		size++
+	}
+	mantissa := uint32(int64(math.Ldexp(f, -(size-3)*8))) & 0xffffff
+	return uint32(size)<<24 + mantissa
+}
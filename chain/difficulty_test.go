@@ -0,0 +1,104 @@
+package chain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/policy"
+	"terorie.dev/nimiq/wire"
+)
+
+// testHeaders creates consecutive headers with the given n-bits and block time.
+func testHeaders(from, to uint32, nBits uint32, blockTime uint32) []*wire.BlockHeader {
+	var headers []*wire.BlockHeader
+	for h := from; h <= to; h++ {
+		headers = append(headers, &wire.BlockHeader{
+			Height:    h,
+			NBits:     nBits,
+			Timestamp: 1_500_000_000 + h*blockTime,
+		})
+	}
+	return headers
+}
+
+func TestDifficulty(t *testing.T) {
+	assert.Equal(t, big.NewRat(1, 1), Difficulty(0x1f010000))
+	assert.Equal(t, big.NewRat(4, 1), Difficulty(0x1e400000))
+	// Rounded to 20 decimal places.
+	assert.Equal(t, "1.00001525902189669642", Difficulty(0x1f00ffff).FloatString(20))
+
+	// Zero targets, either explicit or with the mantissa shifted out.
+	for _, nBits := range []uint32{0x01000000, 0x1f000000, 0x02000080, 0x00123456} {
+		assert.Equal(t, 0, Difficulty(nBits).Sign(), "n-bits %#08x", nBits)
+	}
+}
+
+func TestNextNBits(t *testing.T) {
+	window := policy.DifficultyBlockWindow
+	cases := []struct {
+		name     string
+		headers  []*wire.BlockHeader
+		expected uint32
+	}{
+		{
+			name:     "Genesis",
+			headers:  testHeaders(1, 1, 0x1f010000, 60),
+			expected: 0x1f010000,
+		},
+		{
+			name:     "EarlyChain",
+			headers:  testHeaders(1, 10, 0x1f010000, 60),
+			expected: 0x1f010000,
+		},
+		{
+			name:     "Steady",
+			headers:  testHeaders(1000, 1000+window, 0x1e400000, 60),
+			expected: 0x1e400000,
+		},
+		{
+			name:     "Faster",
+			headers:  testHeaders(1000, 1000+window, 0x1e400000, 30),
+			expected: 0x1e200000,
+		},
+		{
+			// Adjustment is limited to a factor of 2.
+			name:     "Instant",
+			headers:  testHeaders(1000, 1000+window, 0x1e400000, 0),
+			expected: 0x1e200000,
+		},
+		{
+			name:     "Slower",
+			headers:  testHeaders(1000, 1000+window, 0x1e400000, 90),
+			expected: 0x1e600000,
+		},
+		{
+			// Target never exceeds the maximum.
+			name:     "Slowest",
+			headers:  testHeaders(1000, 1000+window, 0x1f010000, 600),
+			expected: 0x1f010000,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			nBits, err := NextNBitsFromHeaders(c.headers)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, nBits, "%08x", nBits)
+		})
+	}
+
+	_, err := NextNBitsFromHeaders(testHeaders(1000, 1000+window-1, 0x1e400000, 60))
+	assert.Error(t, err)
+}
+
+func TestTargetToCompact(t *testing.T) {
+	for _, nBits := range []uint32{0x1f010000, 0x1e400000, 0x1d00ffff, 0x1b0404cb, 0x04008000, 0x02800000, 0x01800000} {
+		target := wire.CompactToTarget(nBits)
+		assert.Equal(t, nBits, targetToCompact(new(big.Rat).SetInt(&target)))
+		assert.Equal(t, nBits, wire.TargetToCompact(target))
+	}
+	// Fractions are kept within the mantissa precision, like in the reference implementation.
+	assert.Equal(t, uint32(0x01018000), targetToCompact(big.NewRat(3, 2)))
+	assert.Equal(t, uint32(0x04012345), targetToCompact(big.NewRat(0x012345ff*2+1, 2)))
+}
@@ -0,0 +1,3 @@
+// Package chain implements the block chain:
+// It validates blocks, chooses the heaviest fork and keeps the accounts at its head.
+package chain
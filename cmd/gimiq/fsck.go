@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"terorie.dev/nimiq/accounts"
+	"terorie.dev/nimiq/tree"
+)
+
+// fsck checks the integrity of an accounts tree database.
+func fsck(args []string) {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	dbPath := flags.String("db", "", "Accounts tree database file (required)")
+	expected := flags.String("hash", "", "Expected accounts hash (hex)")
+	_ = flags.Parse(args)
+
+	if *dbPath == "" {
+		flags.Usage()
+		os.Exit(1)
+	}
+	store, err := tree.OpenBoltStoreReadOnly(*dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+	height, headHash, err := store.Head()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to read database head:", err)
+		os.Exit(1)
+	}
+
+	result := accounts.Check(store)
+	for _, problem := range result.Problems {
+		fmt.Println(problem.Error())
+	}
+	fmt.Printf("Head: block %d %s\n", height, hex.EncodeToString(headHash[:]))
+	fmt.Println("Nodes:", result.Nodes)
+	fmt.Println("Leaves:", result.Leaves)
+	fmt.Println("Root hash:", hex.EncodeToString(result.RootHash[:]))
+	fmt.Println("Problems:", len(result.Problems))
+	ok := len(result.Problems) == 0
+	if *expected != "" && *expected != hex.EncodeToString(result.RootHash[:]) {
+		fmt.Println("Root hash does not match expected hash", *expected)
+		ok = false
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
@@ -3,25 +3,35 @@ package main
 import (
 	"archive/tar"
 	"bufio"
-	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"runtime"
 	"time"
 
 	"terorie.dev/nimiq/accounts"
 	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/chain"
 	"terorie.dev/nimiq/genesis"
 	"terorie.dev/nimiq/tree"
 	"terorie.dev/nimiq/wire"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fsck" {
+		fsck(os.Args[2:])
+		return
+	}
+
 	blocksPath := flag.String("blocksPath", "", "Blocks dump file (required)")
 	profile := flag.String("profile", genesis.ProfileTest, "Genesis profile")
 	debug := flag.Bool("debug", false, "Print debug information")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of signature verification workers")
+	verifyPoW := flag.Bool("pow", false, "Verify proof-of-work and interlinks of blocks")
+	dbPath := flag.String("db", "", "Accounts tree database, resumed from its last block if it exists")
+	keepRevisions := flag.Uint("keep", 0, "Number of past accounts tree revisions to keep in the database")
 	flag.Parse()
 
 	if *blocksPath == "" {
@@ -37,21 +47,129 @@ func main() {
 	rd := bufio.NewReader(f)
 	archive := tar.NewReader(rd)
 
-	store := tree.NewMemStore()
-	pmTree := tree.PMTree{Store: store}
+	var store tree.Store = tree.NewMemStore()
+	var boltStore *tree.BoltStore
+	var headHeight uint32
+	var headHash [32]byte
+	if *dbPath != "" {
+		boltStore, err = tree.OpenBoltStore(*dbPath)
+		if err != nil {
+			panic("failed to open database: " + err.Error())
+		}
+		defer boltStore.Close()
+		headHeight, headHash, err = boltStore.Head()
+		if err != nil {
+			panic("failed to read database head: " + err.Error())
+		}
+		store = boltStore
+	}
+	pmTree := tree.PMTree{Store: store, ParallelHashing: runtime.NumCPU() > 1}
 	accs := accounts.NewAccounts(&pmTree)
+	accs.TxCache = accounts.NewTxCache()
 
 	inf, err := genesis.OpenProfile(*profile)
 	if err != nil {
 		panic("failed to load profile: " + err.Error())
 	}
-	if err := inf.InitAccounts(accs); err != nil {
-		panic(err.Error())
+
+	// Verify signatures of upcoming blocks in parallel,
+	// while applying blocks to the state sequentially.
+	verifier := accounts.NewBatchVerifier(inf.Config.NetworkID, *workers)
+	defer verifier.Close()
+	accs.SkipVerify = true
+	pending := make(chan pendingBlock, 64*(*workers))
+	go readBlocks(archive, verifier, headHeight, pending)
+
+	var blockchain *chain.Chain
+	if headHeight == 0 {
+		if err := inf.InitAccounts(accs); err != nil {
+			panic(err.Error())
+		}
+		blockchain, err = chain.NewChain(accs, &inf.Block)
+		if err != nil {
+			panic("failed to create chain: " + err.Error())
+		}
+		if boltStore != nil {
+			if err := boltStore.Commit(1, blockchain.Head().Hash); err != nil {
+				panic("failed to commit genesis block: " + err.Error())
+			}
+		}
+	} else {
+		// The stored tree is at the head, rebuild the chain up to it from the export.
+		accs.NetworkID = inf.Config.NetworkID
+		resumed := []*wire.Block{&inf.Block}
+		for uint32(len(resumed)) < headHeight {
+			p, ok := <-pending
+			if !ok {
+				panic(fmt.Sprintf("blocks end before database head %d", headHeight))
+			}
+			if p.block.Header.Height > 1 {
+				resumed = append(resumed, p.block)
+			}
+		}
+		if hash := resumed[len(resumed)-1].Header.Hash(); hash != headHash {
+			panic(fmt.Sprintf("database head %x is not in the blocks", headHash))
+		}
+		blockchain, err = chain.ResumeChain(accs, resumed)
+		if err != nil {
+			panic("failed to resume chain: " + err.Error())
+		}
+		fmt.Println("Resuming at block", headHeight)
+	}
+	blockchain.SkipPoW = !*verifyPoW
+	if boltStore != nil && *keepRevisions > 0 {
+		archive := tree.NewArchive(boltStore)
+		archive.Retention = uint32(*keepRevisions)
+		blockchain.SetArchive(archive)
 	}
 
 	start := time.Now()
 	blocks := 0
 	txs := 0
+	for p := range pending {
+		block := p.block
+		if p.verification == nil {
+			panic(fmt.Sprintf("unexpected block %d", block.Header.Height))
+		}
+		if err := p.verification.Wait(); err != nil {
+			panic(fmt.Sprintf("invalid block %d: %s", block.Header.Height, err.Error()))
+		}
+		result, err := blockchain.Push(block)
+		if err != nil {
+			panic(fmt.Sprintf("failed to push block %d: %s", block.Header.Height, err.Error()))
+		}
+		if result == chain.PushKnown {
+			continue
+		} else if result != chain.PushExtended {
+			panic(fmt.Sprintf("block %d does not extend the main chain", block.Header.Height))
+		}
+		if boltStore != nil {
+			head := blockchain.Head()
+			if err := boltStore.Commit(head.Block.Header.Height, head.Hash); err != nil {
+				panic(fmt.Sprintf("failed to commit block %d: %s", block.Header.Height, err.Error()))
+			}
+		}
+		if *debug {
+			fmt.Printf("Block % 6d\n", block.Header.Height)
+		}
+		blocks++
+		txs += len(block.Body.Txs)
+	}
+	fmt.Println("Blocks:", blocks)
+	fmt.Println("Txs:", txs)
+	fmt.Println("Time:", time.Since(start))
+}
+
+// pendingBlock is a block queued for signature verification.
+// Blocks already in the database are not verified again.
+type pendingBlock struct {
+	block        *wire.Block
+	verification *accounts.Verification
+}
+
+// readBlocks decodes blocks from the export and queues those above the stored height for verification.
+func readBlocks(archive *tar.Reader, verifier *accounts.BatchVerifier, storedHeight uint32, pending chan<- pendingBlock) {
+	defer close(pending)
 	_, _ = archive.Next()
 	for {
 		_, err := archive.Next()
@@ -64,24 +182,14 @@ func main() {
 		if err != nil {
 			panic(err.Error())
 		}
-		var block wire.Block
-		if err := beserial.UnmarshalFull(buf, &block); err != nil {
+		block := new(wire.Block)
+		if err := beserial.UnmarshalFull(buf, block); err != nil {
 			panic("failed to unmarshal block: " + err.Error())
 		}
-		if err := accs.Push(&block); err != nil {
-			panic(fmt.Sprintf("failed to commit block %d: %s", block.Header.Height, err.Error()))
-		}
-		hash := pmTree.Hash()
-		if *debug {
-			fmt.Printf("Block % 6d\n", block.Header.Height)
-		}
-		if block.Header.AccountsHash != hash {
-			panic("accounts hash mismatch, expected: " + hex.EncodeToString(block.Header.AccountsHash[:]))
+		p := pendingBlock{block: block}
+		if block.Header.Height > storedHeight {
+			p.verification = verifier.Verify(block)
 		}
-		blocks++
-		txs += len(block.Body.Txs)
+		pending <- p
 	}
-	fmt.Println("Blocks:", blocks)
-	fmt.Println("Txs:", txs)
-	fmt.Println("Time:", time.Since(start))
 }
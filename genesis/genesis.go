@@ -3,6 +3,7 @@ package genesis
 
 import (
 	"embed"
+	"encoding/hex"
 	"fmt"
 	"io/fs"
 	"os"
@@ -36,12 +37,16 @@ type Config struct {
 	GenesisHash [32]byte
 }
 
-// InitAccounts inserts the genesis accounts to the accounts tree.
+// InitAccounts inserts the genesis accounts to the accounts tree
+// and configures the accounts for the network of the profile.
 func (i *Profile) InitAccounts(a *accounts.Accounts) error {
+	a.NetworkID = i.Config.NetworkID
+	a.Tree.Begin()
 	for _, acc := range i.Accounts {
 		a.PutAccount(&acc.Address, acc.Account.Account)
 	}
-	if err := a.Push(&i.Block); err != nil {
+	a.Tree.Commit()
+	if _, err := a.Push(&i.Block); err != nil {
 		return fmt.Errorf("failed to push genesis block: %w", err)
 	}
 	if hash := a.Tree.Hash(); hash != i.Block.Header.AccountsHash {
@@ -113,7 +118,26 @@ func readConfigFromFS(files fs.FS, path string) (conf *Config, err error) {
 	}
 	defer f.Close()
 	dec := toml.NewDecoder(f)
-	conf = new(Config)
-	err = dec.Decode(conf)
-	return
+	var raw struct {
+		NetworkID   uint8    `toml:"network_id"`
+		Name        string   `toml:"name"`
+		SeedPeers   []string `toml:"seed_peers"`
+		SeedLists   []string `toml:"seed_lists"`
+		GenesisHash string   `toml:"genesis_hash"`
+	}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	conf = &Config{
+		NetworkID: raw.NetworkID,
+		Name:      raw.Name,
+		SeedPeers: raw.SeedPeers,
+		SeedLists: raw.SeedLists,
+	}
+	genesisHash, err := hex.DecodeString(raw.GenesisHash)
+	if err != nil || len(genesisHash) != len(conf.GenesisHash) {
+		return nil, fmt.Errorf("invalid genesis hash: %q", raw.GenesisHash)
+	}
+	copy(conf.GenesisHash[:], genesisHash)
+	return conf, nil
 }
@@ -9,13 +9,26 @@ import (
 )
 
 func TestOpenProfile(t *testing.T) {
-	profiles := []string{ProfileMain, ProfileTest}
+	profiles := []struct {
+		name      string
+		networkID uint8
+	}{
+		{ProfileMain, 42},
+		{ProfileTest, 1},
+	}
 	for _, profile := range profiles {
-		t.Run(profile, func(t *testing.T) {
-			inf, err := OpenProfile(profile)
+		t.Run(profile.name, func(t *testing.T) {
+			inf, err := OpenProfile(profile.name)
 			require.NoError(t, err)
+			require.Equal(t, profile.name, inf.Config.Name)
+			require.Equal(t, profile.networkID, inf.Config.NetworkID)
+			require.NotEqual(t, [32]byte{}, inf.Config.GenesisHash)
+			require.Equal(t, inf.Config.GenesisHash, inf.Block.Header.Hash())
+			require.NoError(t, inf.Block.Header.VerifyPoW())
+			require.NoError(t, inf.Block.VerifyBodyHash())
 			accs := accounts.Accounts{Tree: &tree.PMTree{Store: tree.NewMemStore()}}
 			require.NoError(t, inf.InitAccounts(&accs))
+			require.Equal(t, inf.Config.NetworkID, accs.NetworkID)
 		})
 	}
 }
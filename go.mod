@@ -5,5 +5,6 @@ go 1.16
 require (
 	github.com/pelletier/go-toml v1.9.3
 	github.com/stretchr/testify v1.7.0
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b
 )
@@ -87,3 +87,16 @@ func BlockRewardBySupply(supply uint64, height uint32) uint64 {
 	remainder := remaining % EmissionSpeed
 	return (remaining - remainder) / EmissionSpeed
 }
+
+// TxValidityWindow is the number of blocks a transaction can be included in,
+// starting at its validity start height.
+const TxValidityWindow = uint32(120)
+
+// BlockTime is the targeted time between blocks in seconds.
+const BlockTime = uint32(60)
+
+// DifficultyBlockWindow is the number of blocks considered for difficulty adjustment.
+const DifficultyBlockWindow = uint32(120)
+
+// DifficultyMaxAdjustmentFactor limits the change of the target per block.
+const DifficultyMaxAdjustmentFactor = 2.0
@@ -0,0 +1,248 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSnapshotNotFound is returned when a tree revision is not retained.
+var ErrSnapshotNotFound = errors.New("tree snapshot not found")
+
+// ArchiveStore holds the data of an Archive:
+// Nodes addressed by hash with the number of references to them,
+// and the root hash of the snapshot at each block height.
+//
+// MemStore and BoltStore implement it next to the nodes of the tree.
+// A BoltStore writes archive changes on Commit, together with the tree.
+type ArchiveStore interface {
+	// GetArchivedNode returns the node with the given hash and its reference count,
+	// or a nil node if it is not archived.
+	GetArchivedNode(hash [32]byte) (Node, uint32)
+	PutArchivedNode(hash [32]byte, node Node, refs uint32)
+	DelArchivedNode(hash [32]byte)
+	// ArchivedNodeCount returns the number of archived nodes.
+	ArchivedNodeCount() int
+
+	// GetSnapshot returns the root hash of the snapshot at the given height.
+	GetSnapshot(height uint32) ([32]byte, bool)
+	PutSnapshot(height uint32, root [32]byte)
+	DelSnapshot(height uint32)
+	// SnapshotHeights returns the heights of all snapshots in ascending order.
+	SnapshotHeights() []uint32
+}
+
+// archivedNode is a node in an ArchiveStore with its reference count.
+type archivedNode struct {
+	node Node
+	refs uint32
+}
+
+// Archive keeps past revisions of a tree, addressed by block height.
+//
+// Nodes are stored by hash, so revisions share all unchanged subtrees,
+// and taking a snapshot only copies the nodes changed since the last one.
+// Each node counts the references from parent nodes and snapshots,
+// and is removed once no retained revision refers to it.
+//
+// The archive keeps no state of its own besides the ArchiveStore,
+// so a persistent store keeps the retained revisions across restarts.
+type Archive struct {
+	// Retention is the number of most recent heights to keep.
+	// Older snapshots are released when a new one is taken.
+	// Zero keeps all snapshots.
+	Retention uint32
+
+	mu    sync.RWMutex
+	store ArchiveStore
+}
+
+// NewArchive creates an archive in the given store,
+// including the snapshots already in the store.
+func NewArchive(store ArchiveStore) *Archive {
+	return &Archive{store: store}
+}
+
+// Snapshot records the current revision of the tree at the given height,
+// replacing any previous snapshot at that height.
+// Only nodes not already in the archive are copied.
+func (a *Archive) Snapshot(height uint32, t *PMTree) [32]byte {
+	root := t.Hash()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if old, ok := a.store.GetSnapshot(height); ok {
+		a.release(old)
+	}
+	a.retain(root, nil, t.Store)
+	a.store.PutSnapshot(height, root)
+	if a.Retention > 0 && height >= a.Retention {
+		for _, h := range a.store.SnapshotHeights() {
+			if h > height-a.Retention {
+				break
+			}
+			a.releaseSnapshot(h)
+		}
+	}
+	return root
+}
+
+// retain adds a reference to the node with the given hash,
+// copying the node and its new descendants from the store of the tree.
+func (a *Archive) retain(hash [32]byte, prefix Nibbles, store Store) {
+	if node, refs := a.store.GetArchivedNode(hash); node != nil {
+		a.store.PutArchivedNode(hash, node, refs+1)
+		return
+	}
+	node := store.GetNode(prefix)
+	if node == nil {
+		panic(fmt.Sprintf("missing node %s in tree", prefix))
+	}
+	a.store.PutArchivedNode(hash, node, 1)
+	if branch, ok := node.(*Branch); ok {
+		for _, child := range branch.Children {
+			if child.Exists {
+				childPrefix := append(branch.Prefix[:len(branch.Prefix):len(branch.Prefix)], child.Suffix...)
+				a.retain(child.Hash, childPrefix, store)
+			}
+		}
+	}
+}
+
+// release drops a reference to the node with the given hash,
+// removing it and releasing its children once unreferenced.
+func (a *Archive) release(hash [32]byte) {
+	node, refs := a.store.GetArchivedNode(hash)
+	if node == nil {
+		panic(fmt.Sprintf("archive node %x missing", hash))
+	}
+	if refs > 1 {
+		a.store.PutArchivedNode(hash, node, refs-1)
+		return
+	}
+	a.store.DelArchivedNode(hash)
+	if branch, ok := node.(*Branch); ok {
+		for _, child := range branch.Children {
+			if child.Exists {
+				a.release(child.Hash)
+			}
+		}
+	}
+}
+
+// releaseSnapshot removes the snapshot at the given height, if any.
+func (a *Archive) releaseSnapshot(height uint32) {
+	if root, ok := a.store.GetSnapshot(height); ok {
+		a.release(root)
+		a.store.DelSnapshot(height)
+	}
+}
+
+// Release removes the snapshot at the given height.
+func (a *Archive) Release(height uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.releaseSnapshot(height)
+}
+
+// Prune removes all snapshots below the given height.
+func (a *Archive) Prune(height uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, h := range a.store.SnapshotHeights() {
+		if h >= height {
+			break
+		}
+		a.releaseSnapshot(h)
+	}
+}
+
+// Heights returns the heights of all retained snapshots in ascending order.
+func (a *Archive) Heights() []uint32 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.store.SnapshotHeights()
+}
+
+// Root returns the root hash of the snapshot at the given height.
+func (a *Archive) Root(height uint32) ([32]byte, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	root, ok := a.store.GetSnapshot(height)
+	if !ok {
+		return [32]byte{}, ErrSnapshotNotFound
+	}
+	return root, nil
+}
+
+// NodeCount returns the number of distinct nodes stored.
+func (a *Archive) NodeCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.store.ArchivedNodeCount()
+}
+
+// View returns a read-only tree of the snapshot at the given height.
+func (a *Archive) View(height uint32) (*PMTree, error) {
+	root, err := a.Root(height)
+	if err != nil {
+		return nil, err
+	}
+	return a.ViewRoot(root)
+}
+
+// ViewRoot returns a read-only tree with the given root hash.
+//
+// The view must not be modified, and it becomes invalid
+// once the snapshots retaining the root are released.
+func (a *Archive) ViewRoot(root [32]byte) (*PMTree, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	node, _ := a.store.GetArchivedNode(root)
+	if branch, ok := node.(*Branch); !ok || len(branch.Prefix) != 0 {
+		return nil, ErrSnapshotNotFound
+	}
+	return &PMTree{Store: &archiveView{archive: a, root: root}}, nil
+}
+
+// archiveView is a read-only store resolving paths from a root hash.
+type archiveView struct {
+	archive *Archive
+	root    [32]byte
+}
+
+// GetNode walks down from the root to the node at the given path.
+func (v *archiveView) GetNode(nbs Nibbles) Node {
+	v.archive.mu.RLock()
+	defer v.archive.mu.RUnlock()
+	hash := v.root
+	for {
+		node, _ := v.archive.store.GetArchivedNode(hash)
+		if node == nil {
+			panic(fmt.Sprintf("archive node %x missing, snapshot released", hash))
+		}
+		prefix := node.GetPrefix()
+		if len(prefix) == len(nbs) {
+			if prefix.PrefixOf(nbs) {
+				return node
+			}
+			return nil
+		}
+		branch, ok := node.(*Branch)
+		if !ok || len(prefix) > len(nbs) || !prefix.PrefixOf(nbs) {
+			return nil
+		}
+		child := branch.Children[nbs[len(prefix)]]
+		if !child.Exists {
+			return nil
+		}
+		hash = child.Hash
+	}
+}
+
+func (v *archiveView) PutNode(Nibbles, Node) {
+	panic("archive view is read-only")
+}
+
+func (v *archiveView) DelNode(Nibbles) {
+	panic("archive view is read-only")
+}
@@ -0,0 +1,151 @@
+package tree
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	store := NewMemStore()
+	live := &PMTree{Store: store}
+	archive := NewArchive(store)
+	keys := []*[20]byte{{0x01}, {0x02}, {0x12}, {0x12, 0x34}}
+
+	// Height 1: two entries.
+	live.PutEntry(keys[0], []byte{1})
+	live.PutEntry(keys[1], []byte{1})
+	root1 := archive.Snapshot(1, live)
+	nodes1 := archive.NodeCount()
+	assert.Equal(t, live.nodeCount(), nodes1)
+
+	// Height 2: one changed entry shares the untouched leaf.
+	live.PutEntry(keys[1], []byte{2})
+	live.PutEntry(keys[2], []byte{2})
+	live.PutEntry(keys[3], []byte{2})
+	root2 := archive.Snapshot(2, live)
+	assert.Less(t, archive.NodeCount(), nodes1+live.nodeCount())
+
+	// Height 3: a deletion.
+	live.PutEntry(keys[0], nil)
+	root3 := archive.Snapshot(3, live)
+	assert.Equal(t, []uint32{1, 2, 3}, archive.Heights())
+
+	view1, err := archive.View(1)
+	require.NoError(t, err)
+	assert.Equal(t, root1, view1.Hash())
+	assert.Equal(t, []byte{1}, view1.GetEntry(keys[1]))
+	assert.Nil(t, view1.GetEntry(keys[2]))
+
+	view2, err := archive.View(2)
+	require.NoError(t, err)
+	assert.Equal(t, root2, view2.Hash())
+	assert.Equal(t, []byte{1}, view2.GetEntry(keys[0]))
+	assert.Equal(t, []byte{2}, view2.GetEntry(keys[3]))
+	assert.Nil(t, view2.GetEntry(&[20]byte{0x12, 0x35}))
+	// Views support proofs.
+	proof := view2.Prove(keys[2:])
+	require.NoError(t, proof.Verify(root2))
+
+	view3, err := archive.ViewRoot(root3)
+	require.NoError(t, err)
+	assert.Nil(t, view3.GetEntry(keys[0]))
+	assert.Panics(t, func() { view3.PutEntry(keys[0], []byte{3}) })
+
+	// Releasing keeps nodes shared with other snapshots.
+	archive.Prune(3)
+	assert.Equal(t, []uint32{3}, archive.Heights())
+	assert.Equal(t, live.nodeCount(), archive.NodeCount())
+	_, err = archive.View(1)
+	assert.ErrorIs(t, err, ErrSnapshotNotFound)
+	assert.Equal(t, []byte{2}, view3.GetEntry(keys[3]))
+	archive.Release(3)
+	assert.Equal(t, 0, archive.NodeCount())
+}
+
+func TestArchive_Retention(t *testing.T) {
+	store := NewMemStore()
+	live := &PMTree{Store: store}
+	archive := NewArchive(store)
+	archive.Retention = 3
+	for h := uint32(1); h <= 10; h++ {
+		live.PutEntry(&[20]byte{byte(h)}, []byte{byte(h)})
+		archive.Snapshot(h, live)
+	}
+	assert.Equal(t, []uint32{8, 9, 10}, archive.Heights())
+
+	// Replacing a height releases the old revision.
+	live.PutEntry(&[20]byte{10}, nil)
+	root := archive.Snapshot(10, live)
+	view, err := archive.View(10)
+	require.NoError(t, err)
+	assert.Equal(t, root, view.Hash())
+	archive.Release(8)
+	archive.Release(9)
+	assert.Equal(t, live.nodeCount(), archive.NodeCount())
+}
+
+func TestArchive_BoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	store, err := OpenBoltStore(path)
+	require.NoError(t, err)
+	live := &PMTree{Store: store}
+	archive := NewArchive(store)
+	archive.Retention = 2
+	key := &[20]byte{0x01}
+	var roots [4][32]byte
+	for h := uint32(1); h <= 3; h++ {
+		live.PutEntry(key, []byte{byte(h)})
+		live.PutEntry(&[20]byte{0x02}, []byte{0x02})
+		roots[h] = archive.Snapshot(h, live)
+		require.NoError(t, store.Commit(h, [32]byte{byte(h)}))
+	}
+	nodes := archive.NodeCount()
+
+	// Uncommitted snapshots are rolled back with the tree.
+	live.PutEntry(key, []byte{4})
+	archive.Snapshot(4, live)
+	assert.Equal(t, []uint32{3, 4}, archive.Heights())
+	store.Rollback()
+	require.NoError(t, store.Close())
+
+	// Retained revisions survive a restart.
+	store, err = OpenBoltStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	archive = NewArchive(store)
+	assert.Equal(t, []uint32{2, 3}, archive.Heights())
+	assert.Equal(t, nodes, archive.NodeCount())
+	for h := uint32(2); h <= 3; h++ {
+		view, err := archive.View(h)
+		require.NoError(t, err)
+		assert.Equal(t, roots[h], view.Hash())
+		assert.Equal(t, []byte{byte(h)}, view.GetEntry(key))
+	}
+	archive.Prune(3)
+	require.NoError(t, store.Commit(3, [32]byte{0x03}))
+	assert.Equal(t, []uint32{3}, archive.Heights())
+	assert.Equal(t, (&PMTree{Store: store}).nodeCount(), archive.NodeCount())
+}
+
+// nodeCount counts the nodes reachable from the root.
+func (t *PMTree) nodeCount() int {
+	var count func(prefix Nibbles) int
+	count = func(prefix Nibbles) int {
+		node := t.Store.GetNode(prefix)
+		branch, ok := node.(*Branch)
+		if !ok {
+			return 1
+		}
+		n := 1
+		for _, child := range branch.Children {
+			if child.Exists {
+				n += count(append(branch.Prefix[:len(branch.Prefix):len(branch.Prefix)], child.Suffix...))
+			}
+		}
+		return n
+	}
+	return count(nil)
+}
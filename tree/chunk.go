@@ -0,0 +1,226 @@
+package tree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/wire"
+)
+
+// ChunkSizeMax is the maximum number of leaves in a chunk.
+const ChunkSizeMax = 1000
+
+// Chunk import errors.
+var (
+	ErrInvalidChunk      = errors.New("invalid tree chunk")
+	ErrChunkHashMismatch = errors.New("tree chunk does not match root hash")
+	ErrSyncComplete      = errors.New("tree sync already complete")
+)
+
+// Chunk is a range of consecutive leaves in key order.
+//
+// The proof covers the last leaf (the tail), which is not part of Leaves.
+// Together with the hashes in the proof, the leaves determine the root hash.
+type Chunk struct {
+	Leaves []*Leaf
+	Proof  *Proof
+}
+
+// Tail returns the last leaf of the chunk,
+// or nil if the chunk is empty.
+func (c *Chunk) Tail() *Leaf {
+	if len(c.Proof.Nodes) == 0 {
+		return nil
+	}
+	leaf, _ := c.Proof.Nodes[0].(*Leaf)
+	return leaf
+}
+
+// Chunk exports up to size leaves with keys not below start, in key order.
+// The chunk is empty if there are no leaves after start.
+func (t *PMTree) Chunk(start Nibbles, size int) *Chunk {
+	var leaves []*Leaf
+	it := t.Iterate(start)
+	for len(leaves) < size && it.Next() {
+		leaves = append(leaves, it.leaf)
+	}
+	if len(leaves) == 0 {
+		return &Chunk{Proof: t.Prove(nil)}
+	}
+	tail := leaves[len(leaves)-1]
+	key := tail.Prefix.ToKey()
+	return &Chunk{
+		Leaves: leaves[:len(leaves)-1],
+		Proof:  t.Prove([]*[20]byte{&key}),
+	}
+}
+
+// ToWire encodes the chunk as an accounts tree chunk message.
+func (c *Chunk) ToWire() *wire.AccountsTreeChunk {
+	wc := &wire.AccountsTreeChunk{
+		Nodes: make([]wire.AccountsTreeNode, len(c.Leaves)),
+		Proof: *c.Proof.ToWire(),
+	}
+	for i, leaf := range c.Leaves {
+		wc.Nodes[i] = encodeNode(leaf)
+	}
+	return wc
+}
+
+// ChunkFromWire decodes an accounts tree chunk message.
+func ChunkFromWire(wc *wire.AccountsTreeChunk) (*Chunk, error) {
+	proof, err := ProofFromWire(&wc.Proof)
+	if err != nil {
+		return nil, err
+	}
+	c := &Chunk{
+		Leaves: make([]*Leaf, len(wc.Nodes)),
+		Proof:  proof,
+	}
+	for i, buf := range wc.Nodes {
+		leaf := new(Leaf)
+		if err := beserial.UnmarshalFull(buf, leaf); err != nil {
+			return nil, fmt.Errorf("decoding leaf %d: %w", i, err)
+		}
+		c.Leaves[i] = leaf
+	}
+	return c, nil
+}
+
+// ChunkImporter reconstructs a tree chunk by chunk,
+// verifying each chunk against the root hash of the complete tree.
+//
+// Subtrees left of the tail of the latest chunk are complete
+// and must match the hashes in the proof.
+// Subtrees to the right are only known by the hashes in the proof.
+type ChunkImporter struct {
+	tree     *PMTree
+	rootHash [32]byte
+	last     Nibbles // prefix of the last imported leaf
+	complete bool
+}
+
+// NewChunkImporter creates an importer writing to an empty tree.
+func NewChunkImporter(tree *PMTree, rootHash [32]byte) *ChunkImporter {
+	return &ChunkImporter{
+		tree:     tree,
+		rootHash: rootHash,
+	}
+}
+
+// Complete returns whether all leaves of the tree were imported.
+func (c *ChunkImporter) Complete() bool {
+	return c.complete
+}
+
+// NextPrefix returns the start prefix to request the next chunk with.
+func (c *ChunkImporter) NextPrefix() Nibbles {
+	if c.last == nil {
+		return Nibbles{}
+	}
+	return append(c.last[:len(c.last):len(c.last)], 0)
+}
+
+// Push verifies a chunk following the previously imported leaves and adds it to the tree.
+// If an error is returned, the tree is left unchanged.
+func (c *ChunkImporter) Push(chunk *Chunk) error {
+	if c.complete {
+		return ErrSyncComplete
+	}
+	if err := chunk.Proof.Verify(c.rootHash); err != nil {
+		return err
+	}
+	tail := chunk.Tail()
+	path := chunk.Proof.Nodes
+	if tail != nil {
+		path = path[1:]
+	}
+	// The proof must consist of the branches above the tail.
+	for _, node := range path {
+		branch, ok := node.(*Branch)
+		if !ok || (tail != nil && !branch.Prefix.PrefixOf(tail.Prefix)) {
+			return fmt.Errorf("%w: proof is not a path", ErrInvalidChunk)
+		}
+	}
+	leaves := chunk.Leaves
+	if tail != nil {
+		leaves = append(leaves[:len(leaves):len(leaves)], tail)
+	} else if len(leaves) > 0 {
+		return fmt.Errorf("%w: missing tail", ErrInvalidChunk)
+	}
+	// Leaves must be ordered, follow the previous chunk,
+	// and belong to existing subtrees.
+	prev := c.last
+	for _, leaf := range leaves {
+		if len(leaf.Prefix) != 40 || len(leaf.Value) == 0 {
+			return fmt.Errorf("%w: malformed leaf %s", ErrInvalidChunk, leaf.Prefix)
+		}
+		if prev != nil && bytes.Compare(leaf.Prefix, prev) <= 0 {
+			return fmt.Errorf("%w: leaf %s out of order", ErrInvalidChunk, leaf.Prefix)
+		}
+		if !proofCovers(path, leaf.Prefix) {
+			return fmt.Errorf("%w: leaf %s outside of tree", ErrInvalidChunk, leaf.Prefix)
+		}
+		prev = leaf.Prefix
+	}
+	overlay := NewOverlayStore(c.tree.Store)
+	staged := &PMTree{
+		Store:           overlay,
+		ParallelHashing: c.tree.ParallelHashing,
+	}
+	staged.Begin()
+	for _, leaf := range leaves {
+		key := leaf.Prefix.ToKey()
+		staged.PutEntry(&key, leaf.Value)
+	}
+	staged.Commit()
+	// Check the complete subtrees against the proof.
+	complete := true
+	for _, node := range path {
+		branch := node.(*Branch)
+		for _, child := range branch.Children {
+			if !child.Exists {
+				continue
+			}
+			childPrefix := append(branch.Prefix[:len(branch.Prefix):len(branch.Prefix)], child.Suffix...)
+			if tail != nil && childPrefix.PrefixOf(tail.Prefix) {
+				continue // next branch on the path
+			}
+			if tail != nil && bytes.Compare(childPrefix, tail.Prefix) > 0 {
+				complete = false
+				continue
+			}
+			synced := overlay.GetNode(childPrefix)
+			if synced == nil || synced.Hash() != child.Hash {
+				return fmt.Errorf("%w: at node %s", ErrChunkHashMismatch, childPrefix)
+			}
+		}
+	}
+	if complete && staged.Hash() != c.rootHash {
+		return ErrChunkHashMismatch
+	}
+	overlay.Flush()
+	if tail != nil {
+		c.last = tail.Prefix
+	}
+	c.complete = complete
+	return nil
+}
+
+// proofCovers checks whether the deepest branch of the path above the prefix
+// has a child that the prefix belongs to.
+func proofCovers(path []Node, prefix Nibbles) bool {
+	for _, node := range path {
+		// The path is ordered bottom-up.
+		branch := node.(*Branch)
+		if !branch.Prefix.PrefixOf(prefix) {
+			continue
+		}
+		child := branch.Children[prefix[len(branch.Prefix)]]
+		childPrefix := append(branch.Prefix[:len(branch.Prefix):len(branch.Prefix)], child.Suffix...)
+		return child.Exists && childPrefix.PrefixOf(prefix)
+	}
+	return false
+}
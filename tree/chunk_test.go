@@ -0,0 +1,100 @@
+package tree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/wire"
+)
+
+func testChunkTree(t *testing.T, n int) *PMTree {
+	tree := &PMTree{Store: NewMemStore()}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		var key [20]byte
+		rng.Read(key[:])
+		if i%3 == 0 {
+			key[0] = 0x42 // create deeper branches
+		}
+		account := wire.WrapAccount{Account: &wire.BasicAccount{Value: uint64(i + 1)}}
+		buf, err := account.MarshalBESerial(nil)
+		require.NoError(t, err)
+		tree.PutEntry(&key, buf)
+	}
+	return tree
+}
+
+func TestChunkImporter(t *testing.T) {
+	source := testChunkTree(t, 300)
+	target := &PMTree{Store: NewMemStore()}
+	importer := NewChunkImporter(target, source.Hash())
+	var chunks, leaves int
+	for !importer.Complete() {
+		chunk := source.Chunk(importer.NextPrefix(), 17)
+		// Send the chunk over the wire.
+		buf, err := beserial.Marshal(nil, chunk.ToWire())
+		require.NoError(t, err)
+		var wc wire.AccountsTreeChunk
+		require.NoError(t, beserial.UnmarshalFull(buf, &wc))
+		chunk, err = ChunkFromWire(&wc)
+		require.NoError(t, err)
+
+		require.NoError(t, importer.Push(chunk))
+		chunks++
+		leaves += len(chunk.Leaves) + 1
+		require.Less(t, chunks, 100)
+	}
+	assert.Equal(t, 300, leaves)
+	assert.Equal(t, 18, chunks)
+	assert.Equal(t, source.Hash(), target.Hash())
+	assert.ErrorIs(t, importer.Push(source.Chunk(nil, 17)), ErrSyncComplete)
+}
+
+func TestChunkImporter_Empty(t *testing.T) {
+	source := &PMTree{Store: NewMemStore()}
+	chunk := source.Chunk(nil, 10)
+	assert.Nil(t, chunk.Tail())
+	importer := NewChunkImporter(&PMTree{Store: NewMemStore()}, source.Hash())
+	require.NoError(t, importer.Push(chunk))
+	assert.True(t, importer.Complete())
+}
+
+func TestChunkImporter_Invalid(t *testing.T) {
+	source := testChunkTree(t, 100)
+	target := &PMTree{Store: NewMemStore()}
+	emptyHash := target.Hash()
+	importer := NewChunkImporter(target, source.Hash())
+	chunk := source.Chunk(nil, 10)
+
+	// Missing leaf.
+	tampered := &Chunk{
+		Leaves: append(append([]*Leaf(nil), chunk.Leaves[:3]...), chunk.Leaves[4:]...),
+		Proof:  chunk.Proof,
+	}
+	assert.ErrorIs(t, importer.Push(tampered), ErrChunkHashMismatch)
+
+	// Modified leaf.
+	tampered.Leaves = append([]*Leaf(nil), chunk.Leaves...)
+	tampered.Leaves[2] = &Leaf{Prefix: chunk.Leaves[2].Prefix, Value: chunk.Leaves[3].Value}
+	assert.ErrorIs(t, importer.Push(tampered), ErrChunkHashMismatch)
+
+	// Reordered leaves.
+	tampered.Leaves = append([]*Leaf(nil), chunk.Leaves...)
+	tampered.Leaves[2], tampered.Leaves[3] = tampered.Leaves[3], tampered.Leaves[2]
+	assert.ErrorIs(t, importer.Push(tampered), ErrInvalidChunk)
+
+	// Proof for a different tree.
+	other := testChunkTree(t, 99).Chunk(nil, 10)
+	assert.ErrorIs(t, importer.Push(other), ErrProofHashMismatch)
+
+	// Failed imports leave the tree untouched.
+	assert.Equal(t, emptyHash, target.Hash())
+	require.NoError(t, importer.Push(chunk))
+	assert.False(t, importer.Complete())
+
+	// Chunks must not overlap.
+	assert.ErrorIs(t, importer.Push(chunk), ErrInvalidChunk)
+}
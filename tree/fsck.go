@@ -0,0 +1,166 @@
+package tree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Integrity errors reported by Check.
+var (
+	ErrMissingNode       = errors.New("missing node")
+	ErrInvalidRoot       = errors.New("root node is not a branch")
+	ErrCorruptNode       = errors.New("unreadable node")
+	ErrPrefixMismatch    = errors.New("node prefix does not match path")
+	ErrChildHashMismatch = errors.New("child hash mismatch")
+	ErrSingleChildBranch = errors.New("non-root branch with less than two children")
+	ErrInvalidLeaf       = errors.New("invalid leaf")
+	ErrOrphanNode        = errors.New("node not reachable from root")
+)
+
+// Problem is an integrity violation at a node.
+type Problem struct {
+	Prefix Nibbles
+	Err    error
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("node %q: %s", p.Prefix.String(), p.Err)
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// CheckResult is the outcome of an integrity check.
+type CheckResult struct {
+	// RootHash is the hash of the stored root node.
+	RootHash [32]byte
+	// Nodes is the number of nodes reachable from the root.
+	Nodes int
+	// Leaves is the number of leaves reachable from the root.
+	Leaves int
+	// Problems lists all integrity violations found.
+	Problems []*Problem
+}
+
+// Check walks the tree in the store and verifies its structure:
+// Child hashes match the nodes, node prefixes match their paths,
+// non-root branches have at least two children,
+// and leaves are at full-length keys.
+// Leaf values are passed to checkValue, if not nil.
+//
+// If the store implements NodeLister, nodes not reachable from the root are reported too.
+func Check(store Store, checkValue func([]byte) error) *CheckResult {
+	c := &checker{
+		store:      store,
+		checkValue: checkValue,
+		visited:    make(map[string]struct{}),
+		result:     new(CheckResult),
+	}
+	root, err := c.getNode(nil)
+	switch {
+	case err != nil:
+		c.visited[""] = struct{}{}
+		c.report(nil, err)
+	case root == nil:
+		c.report(nil, ErrMissingNode)
+	default:
+		if _, ok := root.(*Branch); !ok {
+			c.report(nil, ErrInvalidRoot)
+		}
+		c.result.RootHash = c.checkNode(nil, root)
+	}
+	if lister, ok := store.(NodeLister); ok {
+		lister.ForEachNode(func(nbs Nibbles, node Node) {
+			if _, ok := c.visited[string(nbs)]; ok {
+				return
+			}
+			if node == nil {
+				c.report(nbs, ErrCorruptNode)
+			} else {
+				c.report(nbs, ErrOrphanNode)
+			}
+		})
+	}
+	return c.result
+}
+
+type checker struct {
+	store      Store
+	checkValue func([]byte) error
+	visited    map[string]struct{}
+	result     *CheckResult
+}
+
+func (c *checker) report(prefix Nibbles, err error) {
+	c.result.Problems = append(c.result.Problems, &Problem{Prefix: prefix, Err: err})
+}
+
+// getNode reads a node, turning storage panics into errors.
+func (c *checker) getNode(prefix Nibbles) (node Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrCorruptNode, r)
+		}
+	}()
+	return c.store.GetNode(prefix), nil
+}
+
+// checkNode verifies the node stored at the path and its subtree,
+// and returns the hash of the node.
+func (c *checker) checkNode(path Nibbles, node Node) [32]byte {
+	c.visited[string(path)] = struct{}{}
+	c.result.Nodes++
+	if !bytes.Equal(node.GetPrefix(), path) {
+		c.report(path, fmt.Errorf("%w: stored prefix %q", ErrPrefixMismatch, node.GetPrefix().String()))
+	}
+	switch n := node.(type) {
+	case *Leaf:
+		c.result.Leaves++
+		c.checkLeaf(path, n)
+	case *Branch:
+		var childCount int
+		for i, child := range n.Children {
+			if !child.Exists {
+				continue
+			}
+			childCount++
+			if len(child.Suffix) == 0 || child.Suffix[0] != byte(i) {
+				c.report(path, fmt.Errorf("%w: child %x has suffix %q", ErrPrefixMismatch, i, child.Suffix.String()))
+				continue
+			}
+			childPath := append(path[:len(path):len(path)], child.Suffix...)
+			childNode, err := c.getNode(childPath)
+			if err != nil {
+				c.visited[string(childPath)] = struct{}{}
+				c.report(childPath, err)
+				continue
+			}
+			if childNode == nil {
+				c.report(childPath, ErrMissingNode)
+				continue
+			}
+			if hash := c.checkNode(childPath, childNode); hash != child.Hash {
+				c.report(childPath, fmt.Errorf("%w: parent has %x, node has %x", ErrChildHashMismatch, child.Hash, hash))
+			}
+		}
+		if len(path) > 0 && childCount < 2 {
+			c.report(path, ErrSingleChildBranch)
+		}
+	}
+	return node.Hash()
+}
+
+// checkLeaf verifies the key length and the value of a leaf.
+func (c *checker) checkLeaf(path Nibbles, leaf *Leaf) {
+	if len(path) != 40 {
+		c.report(path, fmt.Errorf("%w: key has %d nibbles", ErrInvalidLeaf, len(path)))
+	}
+	if c.checkValue == nil {
+		return
+	}
+	if err := c.checkValue(leaf.Value); err != nil {
+		c.report(path, fmt.Errorf("%w: %s", ErrInvalidLeaf, err))
+	}
+}
@@ -0,0 +1,122 @@
+package tree
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/wire"
+)
+
+func testCheckTree(t *testing.T, store Store) *PMTree {
+	tree := &PMTree{Store: store}
+	for i, key := range []*[20]byte{{0x10}, {0x12}, {0x12, 0x34}, {0x80}} {
+		account := wire.WrapAccount{Account: &wire.BasicAccount{Value: uint64(i + 1)}}
+		buf, err := account.MarshalBESerial(nil)
+		require.NoError(t, err)
+		tree.PutEntry(key, buf)
+	}
+	return tree
+}
+
+// checkAccount accepts leaf values that are accounts.
+func checkAccount(value []byte) error {
+	var account wire.WrapAccount
+	return beserial.UnmarshalFull(value, &account)
+}
+
+// checkErrors returns the kinds of problems found.
+func checkErrors(result *CheckResult) (errs []error) {
+	kinds := []error{
+		ErrMissingNode, ErrInvalidRoot, ErrCorruptNode, ErrPrefixMismatch,
+		ErrChildHashMismatch, ErrSingleChildBranch, ErrInvalidLeaf, ErrOrphanNode,
+	}
+	for _, problem := range result.Problems {
+		for _, kind := range kinds {
+			if errors.Is(problem, kind) {
+				errs = append(errs, kind)
+			}
+		}
+	}
+	return
+}
+
+func TestCheck(t *testing.T) {
+	store := NewMemStore()
+	tree := testCheckTree(t, store)
+	result := Check(store, checkAccount)
+	assert.Empty(t, result.Problems)
+	assert.Equal(t, tree.Hash(), result.RootHash)
+	assert.Equal(t, 4, result.Leaves)
+	assert.Equal(t, 7, result.Nodes)
+
+	leafPath := KeyToNibbles(&[20]byte{0x12, 0x34})
+	leaf := store.GetNode(leafPath).(*Leaf)
+	branchPath := Nibbles{0x1}
+
+	cases := []struct {
+		name    string
+		corrupt func(store *MemStore)
+		errs    []error
+	}{
+		{"ModifiedLeaf", func(store *MemStore) {
+			store.PutNode(leafPath, &Leaf{Prefix: leafPath, Value: append([]byte{}, leaf.Value[:len(leaf.Value)-1]...)})
+		}, []error{ErrInvalidLeaf, ErrChildHashMismatch}},
+		{"MissingLeaf", func(store *MemStore) {
+			store.DelNode(leafPath)
+		}, []error{ErrMissingNode}},
+		{"WrongPrefix", func(store *MemStore) {
+			store.PutNode(leafPath, &Leaf{Prefix: KeyToNibbles(&[20]byte{0x12, 0x35}), Value: leaf.Value})
+		}, []error{ErrPrefixMismatch, ErrChildHashMismatch}},
+		{"Orphan", func(store *MemStore) {
+			store.PutNode(Nibbles{0x5}, &Branch{Prefix: Nibbles{0x5}})
+		}, []error{ErrOrphanNode}},
+		{"SingleChild", func(store *MemStore) {
+			branch := store.GetNode(branchPath).(*Branch).clone()
+			branch.Children[2].Exists = false
+			store.PutNode(branchPath, branch)
+		}, []error{ErrSingleChildBranch, ErrChildHashMismatch, ErrOrphanNode, ErrOrphanNode, ErrOrphanNode}},
+		{"LeafRoot", func(store *MemStore) {
+			store.PutNode(nil, &Leaf{Value: leaf.Value})
+		}, []error{ErrInvalidRoot, ErrInvalidLeaf, ErrOrphanNode, ErrOrphanNode, ErrOrphanNode, ErrOrphanNode, ErrOrphanNode, ErrOrphanNode}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemStore()
+			testCheckTree(t, store)
+			c.corrupt(store)
+			assert.ElementsMatch(t, c.errs, checkErrors(Check(store, checkAccount)))
+		})
+	}
+
+	// Without a value check, leaf values are not interpreted.
+	store = NewMemStore()
+	testCheckTree(t, store)
+	store.PutNode(leafPath, &Leaf{Prefix: leafPath, Value: []byte{0x42}})
+	assert.Equal(t, []error{ErrChildHashMismatch}, checkErrors(Check(store, nil)))
+}
+
+func TestCheck_BoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	store, err := OpenBoltStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	testCheckTree(t, store)
+	require.NoError(t, store.Commit(1, [32]byte{}))
+	assert.Empty(t, Check(store, checkAccount).Problems)
+
+	// Damage the stored leaf encoding.
+	leafPath := KeyToNibbles(&[20]byte{0x80})
+	require.NoError(t, store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltNodesBucket).Put(boltNodeKey(leafPath), []byte{0x42})
+	}))
+	result := Check(store, checkAccount)
+	assert.Equal(t, []error{ErrCorruptNode}, checkErrors(result))
+	for _, problem := range result.Problems {
+		assert.Equal(t, leafPath, problem.Prefix)
+	}
+}
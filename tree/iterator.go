@@ -0,0 +1,99 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Iterator walks the entries of a tree in key order.
+// The tree must not be modified while iterating.
+type Iterator struct {
+	store  Store
+	start  Nibbles
+	prefix Nibbles // stop at the first key without the prefix, if set
+	stack  []iteratorFrame
+	leaf   *Leaf
+}
+
+type iteratorFrame struct {
+	branch *Branch
+	next   int // next child index
+}
+
+// Iterate returns an iterator over the entries with keys not below start.
+// An empty start iterates over all entries.
+func (t *PMTree) Iterate(start Nibbles) *Iterator {
+	root := t.Store.GetNode(nil).(*Branch)
+	return &Iterator{
+		store: t.Store,
+		start: start,
+		stack: []iteratorFrame{{branch: root}},
+	}
+}
+
+// IteratePrefix returns an iterator over the entries with keys starting with prefix.
+func (t *PMTree) IteratePrefix(prefix Nibbles) *Iterator {
+	it := t.Iterate(prefix)
+	it.prefix = prefix
+	return it
+}
+
+// Next advances to the next entry.
+// It returns false when there are no more entries.
+func (it *Iterator) Next() bool {
+	it.leaf = nil
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		if top.next >= len(top.branch.Children) {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+		child := top.branch.Children[top.next]
+		top.next++
+		if !child.Exists {
+			continue
+		}
+		prefix := top.branch.Prefix
+		childPrefix := append(prefix[:len(prefix):len(prefix)], child.Suffix...)
+		// Skip subtrees with all keys below start.
+		if bytes.Compare(childPrefix, it.start[:minInt(len(it.start), len(childPrefix))]) < 0 {
+			continue
+		}
+		switch n := it.store.GetNode(childPrefix).(type) {
+		case *Leaf:
+			if bytes.Compare(n.Prefix, it.start) < 0 {
+				continue
+			}
+			if it.prefix != nil && !it.prefix.PrefixOf(n.Prefix) {
+				// All following keys are past the prefix.
+				it.stack = nil
+				return false
+			}
+			it.leaf = n
+			return true
+		case *Branch:
+			it.stack = append(it.stack, iteratorFrame{branch: n})
+		default:
+			panic(fmt.Sprintf("invalid node type in tree: %T", n))
+		}
+	}
+	return false
+}
+
+// Key returns the key of the current entry.
+func (it *Iterator) Key() [20]byte {
+	return it.leaf.Prefix.ToKey()
+}
+
+// Value returns the value of the current entry.
+// The value is shared with the store and must not be modified.
+func (it *Iterator) Value() []byte {
+	return it.leaf.Value
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
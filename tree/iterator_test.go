@@ -0,0 +1,54 @@
+package tree
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterator(t *testing.T) {
+	tree := &PMTree{Store: NewMemStore()}
+	assert.False(t, tree.Iterate(nil).Next())
+
+	rng := rand.New(rand.NewSource(2))
+	keys := make([][20]byte, 200)
+	for i := range keys {
+		rng.Read(keys[i][:])
+		keys[i][0] &= 0x1F // share prefixes
+		tree.PutEntry(&keys[i], []byte{byte(i)})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
+
+	collect := func(it *Iterator) (keys [][20]byte) {
+		for it.Next() {
+			keys = append(keys, it.Key())
+			require.NotEmpty(t, it.Value())
+		}
+		return
+	}
+	assert.Equal(t, keys, collect(tree.Iterate(nil)))
+
+	// Start at an existing key and between keys.
+	start := KeyToNibbles(&keys[50])
+	assert.Equal(t, keys[50:], collect(tree.Iterate(start)))
+	assert.Equal(t, keys[51:], collect(tree.Iterate(append(start, 0))))
+	assert.Empty(t, collect(tree.Iterate(Nibbles{0xf})))
+
+	// Prefix scans.
+	var expected [][20]byte
+	for _, key := range keys {
+		if key[0] == 0x13 {
+			expected = append(expected, key)
+		}
+	}
+	require.NotEmpty(t, expected)
+	assert.Equal(t, expected, collect(tree.IteratePrefix(Nibbles{0x1, 0x3})))
+	assert.Equal(t, keys, collect(tree.IteratePrefix(nil)))
+	assert.Empty(t, collect(tree.IteratePrefix(Nibbles{0x3})))
+}
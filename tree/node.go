@@ -1,14 +1,19 @@
 package tree
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
 
 	"golang.org/x/crypto/blake2b"
+	"terorie.dev/nimiq/beserial"
 )
 
 // Node is an entry in the state tree.
 type Node interface {
 	GetPrefix() Nibbles
+	Hash() [32]byte
 }
 
 // Branch is a branch node in the tree with 16 children.
@@ -31,6 +36,13 @@ func (n *Branch) GetPrefix() Nibbles {
 	return n.Prefix
 }
 
+// clone returns a copy of the branch
+// that can be modified without affecting the original.
+func (n *Branch) clone() *Branch {
+	c := *n
+	return &c
+}
+
 // Hash calculates the tree node hash.
 func (n *Branch) Hash() (sum [32]byte) {
 	h, _ := blake2b.New256(nil)
@@ -86,3 +98,208 @@ func (t *Leaf) Hash() (sum [32]byte) {
 	h.Sum(sum[:0])
 	return
 }
+
+// Node serialization errors.
+var (
+	ErrUnexpectedEOF   = errors.New("unexpected end of node")
+	ErrInvalidNodeType = errors.New("invalid node type")
+	ErrInvalidNibble   = errors.New("invalid hex nibble")
+)
+
+// Node type tags in the serialized form.
+const (
+	nodeTypeBranch = 0x00
+	nodeTypeLeaf   = 0xFF
+)
+
+// encodeNode serializes a node in the same format that is used for hashing:
+// A type byte, the length-prefixed hex prefix,
+// then either the leaf value or the child references of a branch.
+func encodeNode(node Node) []byte {
+	var buf bytes.Buffer
+	switch n := node.(type) {
+	case *Leaf:
+		buf.WriteByte(nodeTypeLeaf)
+		buf.WriteByte(uint8(len(n.Prefix)))
+		buf.Write(n.Prefix.HexBytes())
+		buf.Write(n.Value)
+	case *Branch:
+		buf.WriteByte(nodeTypeBranch)
+		buf.WriteByte(uint8(len(n.Prefix)))
+		buf.Write(n.Prefix.HexBytes())
+		var childCount uint8
+		for _, child := range n.Children {
+			if child.Exists {
+				childCount++
+			}
+		}
+		buf.WriteByte(childCount)
+		for _, child := range n.Children {
+			if !child.Exists {
+				continue
+			}
+			buf.WriteByte(uint8(len(child.Suffix)))
+			buf.Write(child.Suffix.HexBytes())
+			buf.Write(child.Hash[:])
+		}
+	default:
+		panic(fmt.Sprintf("unknown node type %T", node))
+	}
+	return buf.Bytes()
+}
+
+// decodeNode parses a node written by encodeNode.
+// The leaf value spans the rest of the buffer.
+func decodeNode(b []byte) (Node, error) {
+	if len(b) < 1 {
+		return nil, ErrUnexpectedEOF
+	}
+	nodeType := b[0]
+	prefix, b, err := readHexNibbles(b[1:])
+	if err != nil {
+		return nil, fmt.Errorf("reading prefix: %w", err)
+	}
+	switch nodeType {
+	case nodeTypeLeaf:
+		value := make([]byte, len(b))
+		copy(value, b)
+		return &Leaf{Prefix: prefix, Value: value}, nil
+	case nodeTypeBranch:
+		if len(b) < 1 {
+			return nil, ErrUnexpectedEOF
+		}
+		childCount := b[0]
+		b = b[1:]
+		branch := &Branch{Prefix: prefix}
+		for i := uint8(0); i < childCount; i++ {
+			var suffix Nibbles
+			suffix, b, err = readHexNibbles(b)
+			if err != nil {
+				return nil, fmt.Errorf("reading child suffix: %w", err)
+			}
+			if len(suffix) == 0 {
+				return nil, fmt.Errorf("reading child suffix: %w", ErrUnexpectedEOF)
+			}
+			if len(b) < 32 {
+				return nil, fmt.Errorf("reading child hash: %w", ErrUnexpectedEOF)
+			}
+			var hash [32]byte
+			copy(hash[:], b)
+			b = b[32:]
+			branch.PutChild(suffix, hash)
+		}
+		return branch, nil
+	default:
+		return nil, ErrInvalidNodeType
+	}
+}
+
+// readHexNibbles reads a uint8 length-prefixed hex string of nibbles.
+func readHexNibbles(b []byte) (nbs Nibbles, rest []byte, err error) {
+	if len(b) < 1 {
+		return nil, nil, ErrUnexpectedEOF
+	}
+	n := int(b[0])
+	b = b[1:]
+	if len(b) < n {
+		return nil, nil, ErrUnexpectedEOF
+	}
+	if n == 0 {
+		return nil, b, nil
+	}
+	nbs, err = ParseNibbles(string(b[:n]))
+	if err != nil {
+		return nil, nil, err
+	}
+	return nbs, b[n:], nil
+}
+
+// MarshalBESerial encodes the branch in the core-js accounts tree node format,
+// which is also the input to the branch hash.
+func (n *Branch) MarshalBESerial(b []byte) ([]byte, error) {
+	return append(b, encodeNode(n)...), nil
+}
+
+// SizeBESerial returns the size of the encoded branch.
+func (n *Branch) SizeBESerial() (int, error) {
+	size := 3 + len(n.Prefix)
+	for _, child := range n.Children {
+		if child.Exists {
+			size += 1 + len(child.Suffix) + len(child.Hash)
+		}
+	}
+	return size, nil
+}
+
+// UnmarshalBESerial decodes a branch in the core-js accounts tree node format.
+func (n *Branch) UnmarshalBESerial(b []byte) (int, error) {
+	node, err := decodeNode(b)
+	if err != nil {
+		return 0, err
+	}
+	branch, ok := node.(*Branch)
+	if !ok {
+		return 0, ErrInvalidNodeType
+	}
+	*n = *branch
+	return n.SizeBESerial()
+}
+
+// MarshalBESerial encodes the leaf in the core-js accounts tree node format.
+// The value is the serialized account.
+func (t *Leaf) MarshalBESerial(b []byte) ([]byte, error) {
+	return append(b, encodeNode(t)...), nil
+}
+
+// SizeBESerial returns the size of the encoded leaf.
+func (t *Leaf) SizeBESerial() (int, error) {
+	return 2 + len(t.Prefix) + len(t.Value), nil
+}
+
+// UnmarshalBESerial decodes a leaf in the core-js accounts tree node format.
+// The tree does not interpret account values, so the value spans the rest of the buffer.
+// Leaves inside chunks and proofs are delimited by wire.AccountsTreeNode.
+func (t *Leaf) UnmarshalBESerial(b []byte) (int, error) {
+	node, err := decodeNode(b)
+	if err != nil {
+		return 0, err
+	}
+	leaf, ok := node.(*Leaf)
+	if !ok {
+		return 0, ErrInvalidNodeType
+	}
+	*t = *leaf
+	return len(b), nil
+}
+
+// WrapNode implements BESerial for Node,
+// dispatching on the node type byte.
+type WrapNode struct {
+	Node
+}
+
+func (w *WrapNode) UnmarshalBESerial(b []byte) (int, error) {
+	if len(b) < 1 {
+		return 0, ErrUnexpectedEOF
+	}
+	switch b[0] {
+	case nodeTypeBranch:
+		branch := new(Branch)
+		w.Node = branch
+		return branch.UnmarshalBESerial(b)
+	case nodeTypeLeaf:
+		leaf := new(Leaf)
+		w.Node = leaf
+		return leaf.UnmarshalBESerial(b)
+	default:
+		return 0, ErrInvalidNodeType
+	}
+}
+
+func (w *WrapNode) MarshalBESerial(b []byte) ([]byte, error) {
+	return beserial.Marshal(b, w.Node)
+}
+
+func (w *WrapNode) SizeBESerial() (int, error) {
+	return beserial.Size(w.Node)
+}
@@ -6,6 +6,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/wire"
 )
 
 func TestLeaf_Hash(t *testing.T) {
@@ -28,3 +32,60 @@ func TestLeaf_Hash(t *testing.T) {
 		"acd4a1f45c7f608ccc02aef3c7b0dbc7226b0e9c365fa21771df4ce9f44780e6",
 		hex.EncodeToString(hash[:]))
 }
+
+func TestNode_BESerial(t *testing.T) {
+	branch := &Branch{Prefix: Nibbles{0x3}}
+	branch.PutChild(Nibbles{0x0, 0x1}, [32]byte{0x01})
+	branch.PutChild(Nibbles{0xe}, [32]byte{0x02})
+	account := wire.WrapAccount{Account: &wire.BasicAccount{Value: 5}}
+	accountBuf, err := account.MarshalBESerial(nil)
+	require.NoError(t, err)
+	nodes := []Node{
+		&Branch{},
+		&Branch{Prefix: Nibbles{0x1, 0xf}},
+		branch,
+		&Leaf{Prefix: KeyToNibbles(&[20]byte{0xab}), Value: accountBuf},
+	}
+	for _, node := range nodes {
+		buf, err := beserial.Marshal(nil, &WrapNode{node})
+		require.NoError(t, err)
+		size, err := beserial.Size(&WrapNode{node})
+		require.NoError(t, err)
+		assert.Equal(t, len(buf), size)
+		var decoded WrapNode
+		require.NoError(t, beserial.UnmarshalFull(buf, &decoded))
+		assert.Equal(t, node, decoded.Node)
+		// The encoding is the hash input.
+		switch n := node.(type) {
+		case *Branch:
+			assert.Equal(t, n.Hash(), blake2b.Sum256(buf))
+		case *Leaf:
+			assert.Equal(t, n.Hash(), blake2b.Sum256(buf))
+		}
+	}
+	assert.Equal(t,
+		"0001330202303101000000000000000000000000000000000000000000000000000000000000000"+
+			"1650200000000000000000000000000000000000000000000000000000000000000",
+		hex.EncodeToString(encodeNode(branch)))
+
+	// Trailing data after a branch is not consumed, a leaf value spans the rest of the buffer.
+	buf := append(encodeNode(branch), 0x00)
+	var decodedBranch Branch
+	n, err := decodedBranch.UnmarshalBESerial(buf)
+	require.NoError(t, err)
+	assert.Equal(t, len(buf)-1, n)
+	buf = append(encodeNode(nodes[3]), 0x00)
+	var leaf Leaf
+	n, err = leaf.UnmarshalBESerial(buf)
+	require.NoError(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, append(accountBuf, 0x00), leaf.Value)
+
+	_, err = decodedBranch.UnmarshalBESerial(buf)
+	assert.ErrorIs(t, err, ErrInvalidNodeType)
+	_, err = leaf.UnmarshalBESerial(encodeNode(branch))
+	assert.ErrorIs(t, err, ErrInvalidNodeType)
+	var node WrapNode
+	_, err = node.UnmarshalBESerial([]byte{0x01, 0x00})
+	assert.ErrorIs(t, err, ErrInvalidNodeType)
+}
@@ -0,0 +1,186 @@
+package tree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/wire"
+)
+
+// Proof verification errors.
+var (
+	ErrInvalidProof      = errors.New("invalid tree proof")
+	ErrProofHashMismatch = errors.New("tree proof root hash mismatch")
+	ErrProofNotVerified  = errors.New("tree proof not verified")
+	ErrNotProven         = errors.New("entry not covered by tree proof")
+)
+
+// Proof is a set of tree nodes proving the presence or absence of entries.
+//
+// The nodes are ordered bottom-up (post-order):
+// Each branch is preceded by its included children, the root node comes last.
+type Proof struct {
+	Nodes []Node
+	index map[string]Node // set by Verify
+}
+
+// Prove returns the nodes on the paths from the root to the given keys.
+// For a missing key, the path ends at the node that proves its absence.
+func (t *PMTree) Prove(keys []*[20]byte) *Proof {
+	if t.batch {
+		t.updateHashes(nil)
+	}
+	prefixes := make([]Nibbles, len(keys))
+	for i, key := range keys {
+		prefixes[i] = KeyToNibbles(key)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return bytes.Compare(prefixes[i], prefixes[j]) < 0
+	})
+	proof := new(Proof)
+	t.prove(t.Store.GetNode(nil), prefixes, proof)
+	return proof
+}
+
+// prove adds the node and the subtrees leading to the sorted prefixes to the proof.
+func (t *PMTree) prove(node Node, prefixes []Nibbles, proof *Proof) {
+	if branch, ok := node.(*Branch); ok {
+		for i := 0; i < len(prefixes); {
+			prefix := prefixes[i]
+			if len(prefix) <= len(branch.Prefix) || !branch.Prefix.PrefixOf(prefix) {
+				// The branch itself proves the absence.
+				i++
+				continue
+			}
+			// Group prefixes descending into the same child.
+			nibble := prefix[len(branch.Prefix)]
+			j := i + 1
+			for j < len(prefixes) && branch.Prefix.PrefixOf(prefixes[j]) && prefixes[j][len(branch.Prefix)] == nibble {
+				j++
+			}
+			child := branch.Children[nibble]
+			if child.Exists {
+				childPrefix := append(branch.Prefix[:len(branch.Prefix):len(branch.Prefix)], child.Suffix...)
+				// Prefixes diverging inside the child suffix are proven absent by the branch.
+				var inChild []Nibbles
+				for _, p := range prefixes[i:j] {
+					if childPrefix.PrefixOf(p) {
+						inChild = append(inChild, p)
+					}
+				}
+				if len(inChild) > 0 {
+					t.prove(t.Store.GetNode(childPrefix), inChild, proof)
+				}
+			}
+			i = j
+		}
+	}
+	proof.Nodes = append(proof.Nodes, node)
+}
+
+// Verify checks that the nodes form a valid tree with the given root hash.
+// Entries can only be read from the proof after it was verified.
+func (p *Proof) Verify(rootHash [32]byte) error {
+	index := make(map[string]Node, len(p.Nodes))
+	var stack []Node
+	for _, node := range p.Nodes {
+		if branch, ok := node.(*Branch); ok {
+			// Link up the preceding children of the branch.
+			for len(stack) > 0 {
+				child := stack[len(stack)-1]
+				childPrefix := child.GetPrefix()
+				if len(childPrefix) <= len(branch.Prefix) || !branch.Prefix.PrefixOf(childPrefix) {
+					break
+				}
+				ref := branch.Children[childPrefix[len(branch.Prefix)]]
+				if !ref.Exists || !bytes.Equal(ref.Suffix, childPrefix[len(branch.Prefix):]) {
+					return fmt.Errorf("%w: node %s is not a child of %s", ErrInvalidProof, childPrefix, branch.Prefix)
+				}
+				if ref.Hash != child.Hash() {
+					return fmt.Errorf("%w: hash mismatch at node %s", ErrInvalidProof, childPrefix)
+				}
+				stack = stack[:len(stack)-1]
+			}
+		}
+		if _, ok := index[string(node.GetPrefix())]; ok {
+			return fmt.Errorf("%w: duplicate node %s", ErrInvalidProof, node.GetPrefix())
+		}
+		index[string(node.GetPrefix())] = node
+		stack = append(stack, node)
+	}
+	// Exactly the root node must be left.
+	if len(stack) != 1 {
+		return fmt.Errorf("%w: %d unlinked nodes", ErrInvalidProof, len(stack))
+	}
+	root, ok := stack[0].(*Branch)
+	if !ok || len(root.Prefix) != 0 {
+		return fmt.Errorf("%w: missing root node", ErrInvalidProof)
+	}
+	if root.Hash() != rootHash {
+		return ErrProofHashMismatch
+	}
+	p.index = index
+	return nil
+}
+
+// GetEntry returns the proven value of an entry, or nil if the entry is proven to not exist.
+// The proof must have been verified.
+func (p *Proof) GetEntry(key *[20]byte) ([]byte, error) {
+	if p.index == nil {
+		return nil, ErrProofNotVerified
+	}
+	prefix := KeyToNibbles(key)
+	node := p.index[""]
+	for {
+		switch n := node.(type) {
+		case *Leaf:
+			if bytes.Equal(n.Prefix, prefix) {
+				return n.Value, nil
+			}
+			return nil, nil
+		case *Branch:
+			if len(prefix) <= len(n.Prefix) || !n.Prefix.PrefixOf(prefix) {
+				return nil, nil
+			}
+			child := n.Children[prefix[len(n.Prefix)]]
+			childPrefix := append(n.Prefix[:len(n.Prefix):len(n.Prefix)], child.Suffix...)
+			if !child.Exists || !childPrefix.PrefixOf(prefix) {
+				return nil, nil
+			}
+			var ok bool
+			node, ok = p.index[string(childPrefix)]
+			if !ok {
+				return nil, ErrNotProven
+			}
+		default:
+			panic(fmt.Sprintf("invalid node type in proof: %T", n))
+		}
+	}
+}
+
+// ToWire encodes the proof as an accounts proof message.
+func (p *Proof) ToWire() *wire.AccountsProof {
+	wp := &wire.AccountsProof{
+		Nodes: make([]wire.AccountsTreeNode, len(p.Nodes)),
+	}
+	for i, node := range p.Nodes {
+		wp.Nodes[i] = encodeNode(node)
+	}
+	return wp
+}
+
+// ProofFromWire decodes the nodes of an accounts proof message.
+func ProofFromWire(wp *wire.AccountsProof) (*Proof, error) {
+	p := &Proof{Nodes: make([]Node, len(wp.Nodes))}
+	for i, buf := range wp.Nodes {
+		var node WrapNode
+		if err := beserial.UnmarshalFull(buf, &node); err != nil {
+			return nil, fmt.Errorf("decoding node %d: %w", i, err)
+		}
+		p.Nodes[i] = node.Node
+	}
+	return p, nil
+}
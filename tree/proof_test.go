@@ -0,0 +1,111 @@
+package tree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/wire"
+)
+
+func testProofTree(t *testing.T) (*PMTree, []*[20]byte) {
+	tree := &PMTree{Store: NewMemStore()}
+	keys := []*[20]byte{{}, {0x10}, {0x12}, {0x12, 0x34}, {0x80}}
+	for i, key := range keys {
+		account := wire.WrapAccount{Account: &wire.BasicAccount{Value: uint64(i + 1)}}
+		buf, err := account.MarshalBESerial(nil)
+		require.NoError(t, err)
+		tree.PutEntry(key, buf)
+	}
+	return tree, keys
+}
+
+func TestPMTree_Prove(t *testing.T) {
+	tree, keys := testProofTree(t)
+	root := tree.Hash()
+
+	missing := []*[20]byte{{0x11}, {0x12, 0x30}, {0xff}}
+	proof := tree.Prove([]*[20]byte{keys[3], keys[1], missing[0], missing[1], missing[2]})
+	_, err := proof.GetEntry(keys[1])
+	assert.ErrorIs(t, err, ErrProofNotVerified)
+	require.NoError(t, proof.Verify(root))
+
+	for _, key := range []*[20]byte{keys[1], keys[3]} {
+		value, err := proof.GetEntry(key)
+		require.NoError(t, err)
+		assert.Equal(t, tree.GetEntry(key), value)
+	}
+	for _, key := range missing {
+		value, err := proof.GetEntry(key)
+		require.NoError(t, err)
+		assert.Nil(t, value)
+	}
+	// Sibling leaves are only referenced by hash.
+	_, err = proof.GetEntry(keys[2])
+	assert.ErrorIs(t, err, ErrNotProven)
+
+	// The proof survives the wire encoding.
+	buf, err := beserial.Marshal(nil, proof.ToWire())
+	require.NoError(t, err)
+	var wp wire.AccountsProof
+	require.NoError(t, beserial.UnmarshalFull(buf, &wp))
+	decoded, err := ProofFromWire(&wp)
+	require.NoError(t, err)
+	require.NoError(t, decoded.Verify(root))
+	value, err := decoded.GetEntry(keys[3])
+	require.NoError(t, err)
+	assert.Equal(t, tree.GetEntry(keys[3]), value)
+
+	assert.ErrorIs(t, proof.Verify([32]byte{}), ErrProofHashMismatch)
+}
+
+func TestPMTree_Prove_Absence(t *testing.T) {
+	tree, _ := testProofTree(t)
+	root := tree.Hash()
+	cases := []struct {
+		key   *[20]byte
+		nodes int
+	}{
+		// No child at the nibble.
+		{&[20]byte{0xff}, 1},
+		// Diverges inside the suffix of leaf 0x80.
+		{&[20]byte{0x81}, 1},
+		// Diverges inside the suffix of leaf 0x1234 below branches 1 and 12.
+		{&[20]byte{0x12, 0x30}, 3},
+	}
+	for _, c := range cases {
+		proof := tree.Prove([]*[20]byte{c.key})
+		assert.Len(t, proof.Nodes, c.nodes, "key %x", *c.key)
+		require.NoError(t, proof.Verify(root))
+		value, err := proof.GetEntry(c.key)
+		require.NoError(t, err)
+		assert.Nil(t, value)
+	}
+}
+
+func TestProof_Verify_Invalid(t *testing.T) {
+	tree, keys := testProofTree(t)
+	root := tree.Hash()
+	valid := tree.Prove(keys[3:4]).Nodes
+	require.Greater(t, len(valid), 2)
+
+	// Tampered leaf value.
+	nodes := append([]Node(nil), valid...)
+	leaf := *nodes[0].(*Leaf)
+	leaf.Value = append([]byte(nil), leaf.Value...)
+	leaf.Value[len(leaf.Value)-1]++
+	nodes[0] = &leaf
+	assert.ErrorIs(t, (&Proof{Nodes: nodes}).Verify(root), ErrInvalidProof)
+
+	// Missing root.
+	assert.ErrorIs(t, (&Proof{Nodes: valid[:len(valid)-1]}).Verify(root), ErrInvalidProof)
+
+	// Node not in the tree.
+	nodes = append([]Node{&Leaf{Prefix: KeyToNibbles(&[20]byte{0x90})}}, valid...)
+	assert.ErrorIs(t, (&Proof{Nodes: nodes}).Verify(root), ErrInvalidProof)
+
+	// Duplicate node.
+	nodes = append([]Node{valid[0]}, valid...)
+	assert.ErrorIs(t, (&Proof{Nodes: nodes}).Verify(root), ErrInvalidProof)
+}
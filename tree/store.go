@@ -1,12 +1,22 @@
 package tree
 
 // Store is the low-level key-value storage backend
+//
+// Nodes returned by GetNode may be shared with the store and must not be modified.
+// Changes to a node are written back with PutNode.
 type Store interface {
 	GetNode(Nibbles) Node
 	PutNode(Nibbles, Node)
 	DelNode(Nibbles)
 }
 
+// NodeLister is implemented by stores that can enumerate all stored nodes.
+// The order of nodes is unspecified.
+// Nodes that exist but cannot be read are passed as nil.
+type NodeLister interface {
+	ForEachNode(fn func(Nibbles, Node))
+}
+
 // Nibbles is a list of 4-bit segments.
 // The list is uncompressed, i.e. each byte has only the lower 4 bit set.
 type Nibbles []byte
@@ -56,6 +66,23 @@ func (nbs Nibbles) HexBytes() []byte {
 	return hexBuf
 }
 
+// ParseNibbles reads nibbles from a lowercase hex string.
+func ParseNibbles(s string) (Nibbles, error) {
+	nbs := make(Nibbles, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			nbs[i] = c - '0'
+		case c >= 'a' && c <= 'f':
+			nbs[i] = c - 'a' + 10
+		default:
+			return nil, ErrInvalidNibble
+		}
+	}
+	return nbs, nil
+}
+
 func (nbs Nibbles) String() string {
 	return string(nbs.HexBytes())
 }
@@ -0,0 +1,478 @@
+package tree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bolt store errors.
+var (
+	ErrStoreClosed = errors.New("tree store closed")
+	ErrNotTreeDB   = errors.New("not a tree database")
+)
+
+// boltOpenTimeout is how long to wait for the file lock
+// of a database opened by another process.
+const boltOpenTimeout = time.Second
+
+// boltNodesBucket holds the nodes of the tree keyed by path.
+var boltNodesBucket = []byte("nodes")
+
+// boltMetaBucket holds the height and hash of the block of the committed tree
+// under boltHeadKey.
+var (
+	boltMetaBucket = []byte("meta")
+	boltHeadKey    = []byte("head")
+)
+
+// boltArchiveBucket holds archived nodes keyed by hash,
+// each prefixed with its 4-byte reference count.
+// boltSnapshotsBucket holds the snapshot root hashes keyed by 4-byte height.
+var (
+	boltArchiveBucket   = []byte("archive")
+	boltSnapshotsBucket = []byte("snapshots")
+)
+
+// BoltStore is a persistent store backed by a bbolt database file.
+//
+// Changes are buffered in memory until Commit writes them in a single transaction,
+// so the database always holds the tree as of the last commit.
+// Storage errors in GetNode cause a panic, as the Store interface has no error returns.
+// After Close, changes are still buffered but can no longer be committed.
+//
+// Archive data is buffered and committed the same way,
+// so archived revisions always match the committed tree.
+type BoltStore struct {
+	db      *bolt.DB
+	pending map[string]Node // nil value marks a deletion
+	closed  bool
+
+	// archiveMu guards the buffered archive changes,
+	// which are read by archive views concurrently with commits.
+	archiveMu        sync.RWMutex
+	pendingArchived  map[[32]byte]*archivedNode // nil value marks a deletion
+	pendingSnapshots map[uint32]*[32]byte       // nil value marks a deletion
+}
+
+// OpenBoltStore opens or creates the database file at path.
+// A new database starts out with an empty tree.
+// An existing database is used as is, even if its root node is missing.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltMetaBucket, boltArchiveBucket, boltSnapshotsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(boltNodesBucket) != nil {
+			return nil
+		}
+		bucket, err := tx.CreateBucket(boltNodesBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(boltNodeKey(nil), encodeNode(&Branch{}))
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return newBoltStore(db), nil
+}
+
+func newBoltStore(db *bolt.DB) *BoltStore {
+	return &BoltStore{
+		db:               db,
+		pending:          make(map[string]Node),
+		pendingArchived:  make(map[[32]byte]*archivedNode),
+		pendingSnapshots: make(map[uint32]*[32]byte),
+	}
+}
+
+// OpenBoltStoreReadOnly opens an existing database file for inspection.
+// Nothing is written to the file, so damage is seen as is.
+// Changes can be staged, but Commit fails.
+func OpenBoltStoreReadOnly(path string) (*BoltStore, error) {
+	// bbolt creates missing files even in read-only mode.
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltNodesBucket) == nil || tx.Bucket(boltMetaBucket) == nil {
+			return ErrNotTreeDB
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return newBoltStore(db), nil
+}
+
+// boltNodeKey maps a path to a database key.
+// bbolt does not permit empty keys, so every key carries a one-byte tag.
+func boltNodeKey(nbs Nibbles) []byte {
+	key := make([]byte, 1+len(nbs))
+	key[0] = 'n'
+	copy(key[1:], nbs)
+	return key
+}
+
+// GetNode reads a node from the uncommitted changes or the database.
+func (s *BoltStore) GetNode(nbs Nibbles) Node {
+	if node, ok := s.pending[string(nbs)]; ok {
+		return node
+	}
+	var node Node
+	err := s.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket(boltNodesBucket).Get(boltNodeKey(nbs))
+		if buf == nil {
+			return nil
+		}
+		var err error
+		node, err = decodeNode(buf)
+		return err
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to read node %s: %s", nbs, err))
+	}
+	return node
+}
+
+// PutNode stages a node to be written on the next commit.
+func (s *BoltStore) PutNode(nbs Nibbles, node Node) {
+	s.pending[string(nbs)] = node
+}
+
+// DelNode stages a node deletion for the next commit.
+func (s *BoltStore) DelNode(nbs Nibbles) {
+	s.pending[string(nbs)] = nil
+}
+
+// ForEachNode calls fn for each node, including uncommitted changes.
+// Nodes that fail to decode are passed as nil.
+func (s *BoltStore) ForEachNode(fn func(Nibbles, Node)) {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltNodesBucket).ForEach(func(key, buf []byte) error {
+			nbs := Nibbles(key[1:])
+			if _, ok := s.pending[string(nbs)]; ok {
+				return nil
+			}
+			node, _ := decodeNode(buf)
+			fn(append(Nibbles(nil), nbs...), node)
+			return nil
+		})
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to list nodes: %s", err))
+	}
+	for key, node := range s.pending {
+		if node != nil {
+			fn(Nibbles(key), node)
+		}
+	}
+}
+
+// Head returns the block height and hash recorded by the last commit.
+// The height is zero if nothing was committed yet.
+func (s *BoltStore) Head() (height uint32, hash [32]byte, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket(boltMetaBucket).Get(boltHeadKey)
+		if buf == nil {
+			return nil
+		}
+		if len(buf) != 4+len(hash) {
+			return fmt.Errorf("invalid head record of %d bytes", len(buf))
+		}
+		height = binary.BigEndian.Uint32(buf[:4])
+		copy(hash[:], buf[4:])
+		return nil
+	})
+	return
+}
+
+// Commit atomically writes all staged changes to the database,
+// together with the height and hash of the block the tree state belongs to.
+func (s *BoltStore) Commit(height uint32, hash [32]byte) error {
+	if s.closed {
+		return ErrStoreClosed
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		head := make([]byte, 4+len(hash))
+		binary.BigEndian.PutUint32(head[:4], height)
+		copy(head[4:], hash[:])
+		if err := tx.Bucket(boltMetaBucket).Put(boltHeadKey, head); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(boltNodesBucket)
+		for key, node := range s.pending {
+			dbKey := boltNodeKey(Nibbles(key))
+			var err error
+			if node == nil {
+				err = bucket.Delete(dbKey)
+			} else {
+				err = bucket.Put(dbKey, encodeNode(node))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return s.commitArchive(tx)
+	})
+	if err != nil {
+		return err
+	}
+	s.pending = make(map[string]Node)
+	s.resetArchive()
+	return nil
+}
+
+// commitArchive writes the buffered archive changes.
+func (s *BoltStore) commitArchive(tx *bolt.Tx) error {
+	s.archiveMu.RLock()
+	defer s.archiveMu.RUnlock()
+	archived := tx.Bucket(boltArchiveBucket)
+	for hash, entry := range s.pendingArchived {
+		var err error
+		if entry == nil {
+			err = archived.Delete(hash[:])
+		} else {
+			err = archived.Put(hash[:], encodeArchivedNode(entry))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	snapshots := tx.Bucket(boltSnapshotsBucket)
+	for height, root := range s.pendingSnapshots {
+		var err error
+		if root == nil {
+			err = snapshots.Delete(boltHeightKey(height))
+		} else {
+			err = snapshots.Put(boltHeightKey(height), root[:])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetArchive discards the buffered archive changes.
+func (s *BoltStore) resetArchive() {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+	s.pendingArchived = make(map[[32]byte]*archivedNode)
+	s.pendingSnapshots = make(map[uint32]*[32]byte)
+}
+
+// Rollback discards all uncommitted changes.
+func (s *BoltStore) Rollback() {
+	s.pending = make(map[string]Node)
+	s.resetArchive()
+}
+
+// Close closes the database file, discarding uncommitted changes.
+func (s *BoltStore) Close() error {
+	if s.closed {
+		return ErrStoreClosed
+	}
+	s.closed = true
+	s.pending = make(map[string]Node)
+	s.resetArchive()
+	return s.db.Close()
+}
+
+func boltHeightKey(height uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, height)
+	return key
+}
+
+func encodeArchivedNode(entry *archivedNode) []byte {
+	buf := make([]byte, 4, 4+64)
+	binary.BigEndian.PutUint32(buf, entry.refs)
+	return append(buf, encodeNode(entry.node)...)
+}
+
+func decodeArchivedNode(buf []byte) (*archivedNode, error) {
+	if len(buf) < 4 {
+		return nil, ErrUnexpectedEOF
+	}
+	node, err := decodeNode(buf[4:])
+	if err != nil {
+		return nil, err
+	}
+	return &archivedNode{node: node, refs: binary.BigEndian.Uint32(buf)}, nil
+}
+
+// GetArchivedNode reads an archived node from the uncommitted changes or the database.
+func (s *BoltStore) GetArchivedNode(hash [32]byte) (Node, uint32) {
+	s.archiveMu.RLock()
+	entry, ok := s.pendingArchived[hash]
+	s.archiveMu.RUnlock()
+	if ok {
+		if entry == nil {
+			return nil, 0
+		}
+		return entry.node, entry.refs
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltArchiveBucket)
+		if bucket == nil {
+			return nil
+		}
+		buf := bucket.Get(hash[:])
+		if buf == nil {
+			return nil
+		}
+		var err error
+		entry, err = decodeArchivedNode(buf)
+		return err
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to read archived node %x: %s", hash, err))
+	}
+	if entry == nil {
+		return nil, 0
+	}
+	return entry.node, entry.refs
+}
+
+// PutArchivedNode stages an archived node to be written on the next commit.
+func (s *BoltStore) PutArchivedNode(hash [32]byte, node Node, refs uint32) {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+	s.pendingArchived[hash] = &archivedNode{node: node, refs: refs}
+}
+
+// DelArchivedNode stages an archived node deletion for the next commit.
+func (s *BoltStore) DelArchivedNode(hash [32]byte) {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+	s.pendingArchived[hash] = nil
+}
+
+// ArchivedNodeCount counts the archived nodes, including uncommitted changes.
+// It walks all archived nodes in the database.
+func (s *BoltStore) ArchivedNodeCount() int {
+	s.archiveMu.RLock()
+	defer s.archiveMu.RUnlock()
+	var count int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltArchiveBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, _ []byte) error {
+			var hash [32]byte
+			copy(hash[:], key)
+			if _, ok := s.pendingArchived[hash]; !ok {
+				count++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to count archived nodes: %s", err))
+	}
+	for _, entry := range s.pendingArchived {
+		if entry != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// GetSnapshot reads a snapshot root hash from the uncommitted changes or the database.
+func (s *BoltStore) GetSnapshot(height uint32) (root [32]byte, ok bool) {
+	s.archiveMu.RLock()
+	pending, isPending := s.pendingSnapshots[height]
+	s.archiveMu.RUnlock()
+	if isPending {
+		if pending == nil {
+			return root, false
+		}
+		return *pending, true
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltSnapshotsBucket)
+		if bucket == nil {
+			return nil
+		}
+		buf := bucket.Get(boltHeightKey(height))
+		if buf == nil {
+			return nil
+		}
+		if len(buf) != len(root) {
+			return fmt.Errorf("invalid snapshot root of %d bytes", len(buf))
+		}
+		copy(root[:], buf)
+		ok = true
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to read snapshot %d: %s", height, err))
+	}
+	return root, ok
+}
+
+// PutSnapshot stages a snapshot root hash to be written on the next commit.
+func (s *BoltStore) PutSnapshot(height uint32, root [32]byte) {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+	s.pendingSnapshots[height] = &root
+}
+
+// DelSnapshot stages a snapshot deletion for the next commit.
+func (s *BoltStore) DelSnapshot(height uint32) {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+	s.pendingSnapshots[height] = nil
+}
+
+// SnapshotHeights returns the heights of all snapshots in ascending order,
+// including uncommitted changes.
+func (s *BoltStore) SnapshotHeights() []uint32 {
+	s.archiveMu.RLock()
+	defer s.archiveMu.RUnlock()
+	var heights []uint32
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltSnapshotsBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, _ []byte) error {
+			height := binary.BigEndian.Uint32(key)
+			if _, ok := s.pendingSnapshots[height]; !ok {
+				heights = append(heights, height)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to list snapshots: %s", err))
+	}
+	for height, root := range s.pendingSnapshots {
+		if root != nil {
+			heights = append(heights, height)
+		}
+	}
+	sortHeights(heights)
+	return heights
+}
@@ -0,0 +1,140 @@
+package tree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	store, err := OpenBoltStore(path)
+	require.NoError(t, err)
+	memTree := &PMTree{Store: NewMemStore()}
+	boltTree := &PMTree{Store: store}
+	assert.Equal(t, memTree.Hash(), boltTree.Hash())
+
+	keys := []*[20]byte{{}, {0x10}, {0x12}, {0x12, 0x34}}
+	for i, key := range keys {
+		value := []byte{byte(i), 0xAA}
+		memTree.PutEntry(key, value)
+		boltTree.PutEntry(key, value)
+	}
+	committedHash := memTree.Hash()
+	require.Equal(t, committedHash, boltTree.Hash())
+	height, head, err := store.Head()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), height)
+	require.NoError(t, store.Commit(2, [32]byte{0x02}))
+
+	// Uncommitted changes are lost on close.
+	boltTree.PutEntry(&[20]byte{0xFF}, []byte{0x01})
+	assert.NotEqual(t, committedHash, boltTree.Hash())
+	require.NoError(t, store.Close())
+
+	store, err = OpenBoltStore(path)
+	require.NoError(t, err)
+	boltTree = &PMTree{Store: store}
+	assert.Equal(t, committedHash, boltTree.Hash())
+	height, head, err = store.Head()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), height)
+	assert.Equal(t, [32]byte{0x02}, head)
+	for i, key := range keys {
+		assert.Equal(t, []byte{byte(i), 0xAA}, boltTree.GetEntry(key))
+	}
+	assert.Nil(t, boltTree.GetEntry(&[20]byte{0xFF}))
+
+	// Deletions are persisted too.
+	boltTree.PutEntry(keys[3], nil)
+	memTree.PutEntry(keys[3], nil)
+	require.NoError(t, store.Commit(3, [32]byte{0x03}))
+	assert.Equal(t, memTree.Hash(), boltTree.Hash())
+	store.Rollback()
+	assert.Nil(t, boltTree.GetEntry(keys[3]))
+
+	// Writes after close do not panic, but cannot be committed.
+	require.NoError(t, store.Close())
+	store.PutNode(Nibbles{0x1}, &Branch{})
+	store.DelNode(Nibbles{0x1})
+	assert.ErrorIs(t, store.Commit(4, [32]byte{0x04}), ErrStoreClosed)
+	assert.ErrorIs(t, store.Close(), ErrStoreClosed)
+}
+
+func TestOpenBoltStoreReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tree.db")
+	_, err := OpenBoltStoreReadOnly(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.NoFileExists(t, path)
+
+	store, err := OpenBoltStore(path)
+	require.NoError(t, err)
+	tree := &PMTree{Store: store}
+	tree.PutEntry(&[20]byte{0x10}, []byte{0x01})
+	require.NoError(t, store.Commit(2, [32]byte{0x02}))
+	// Lose the root node.
+	require.NoError(t, store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltNodesBucket).Delete(boltNodeKey(nil))
+	}))
+	require.NoError(t, store.Close())
+
+	// Neither open mode recreates the root.
+	store, err = OpenBoltStore(path)
+	require.NoError(t, err)
+	assert.Nil(t, store.GetNode(nil))
+	require.NoError(t, store.Close())
+	store, err = OpenBoltStoreReadOnly(path)
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Nil(t, store.GetNode(nil))
+	assert.NotNil(t, store.GetNode(KeyToNibbles(&[20]byte{0x10})))
+	height, _, err := store.Head()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), height)
+	assert.ErrorIs(t, store.Commit(3, [32]byte{0x03}), bolt.ErrDatabaseReadOnly)
+
+	// Writers wait for readers to release the file lock.
+	_, err = OpenBoltStore(path)
+	assert.ErrorIs(t, err, bolt.ErrTimeout)
+
+	otherPath := filepath.Join(dir, "other.db")
+	db, err := bolt.Open(otherPath, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	_, err = OpenBoltStoreReadOnly(otherPath)
+	assert.ErrorIs(t, err, ErrNotTreeDB)
+}
+
+func TestNodeEncoding(t *testing.T) {
+	nodes := []Node{
+		&Branch{},
+		&Branch{Prefix: Nibbles{0x1, 0xf}},
+		&Leaf{Prefix: KeyToNibbles(&[20]byte{0xab}), Value: []byte{1, 2, 3}},
+	}
+	branch := &Branch{Prefix: Nibbles{0x3}}
+	branch.PutChild(Nibbles{0x0, 0x1}, [32]byte{0x01})
+	branch.PutChild(Nibbles{0xe}, [32]byte{0x02})
+	nodes = append(nodes, branch)
+	for _, node := range nodes {
+		decoded, err := decodeNode(encodeNode(node))
+		require.NoError(t, err)
+		assert.Equal(t, node, decoded)
+	}
+
+	leaf := nodes[2].(*Leaf)
+	assert.Equal(t, leaf.Hash(), blake2b.Sum256(encodeNode(leaf)))
+	assert.Equal(t, branch.Hash(), blake2b.Sum256(encodeNode(branch)))
+
+	_, err := decodeNode([]byte{0x01, 0x00})
+	assert.ErrorIs(t, err, ErrInvalidNodeType)
+	_, err = decodeNode([]byte{0x00, 0x01, 'g', 0x00})
+	assert.ErrorIs(t, err, ErrInvalidNibble)
+	_, err = decodeNode([]byte{0x00, 0x00, 0x01, 0x01, '0'})
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+}
@@ -1,8 +1,13 @@
 package tree
 
+import "sort"
+
 // MemStore is a store backed by a Go map in memory.
+// It also holds archived nodes for an Archive.
 type MemStore struct {
-	nodes map[string]Node
+	nodes     map[string]Node
+	archived  map[[32]byte]archivedNode
+	snapshots map[uint32][32]byte
 }
 
 // NewMemStore creates a new, empty MemStore.
@@ -11,6 +16,8 @@ func NewMemStore() *MemStore {
 		nodes: map[string]Node{
 			"": &Branch{},
 		},
+		archived:  make(map[[32]byte]archivedNode),
+		snapshots: make(map[uint32][32]byte),
 	}
 }
 
@@ -28,3 +35,63 @@ func (m *MemStore) PutNode(nbs Nibbles, node Node) {
 func (m *MemStore) DelNode(nbs Nibbles) {
 	delete(m.nodes, string(nbs))
 }
+
+// ForEachNode calls fn for each stored node.
+func (m *MemStore) ForEachNode(fn func(Nibbles, Node)) {
+	for key, node := range m.nodes {
+		fn(Nibbles(key), node)
+	}
+}
+
+// GetArchivedNode returns an archived node and its reference count.
+func (m *MemStore) GetArchivedNode(hash [32]byte) (Node, uint32) {
+	entry := m.archived[hash]
+	return entry.node, entry.refs
+}
+
+// PutArchivedNode sets an archived node and its reference count.
+func (m *MemStore) PutArchivedNode(hash [32]byte, node Node, refs uint32) {
+	m.archived[hash] = archivedNode{node: node, refs: refs}
+}
+
+// DelArchivedNode removes an archived node.
+func (m *MemStore) DelArchivedNode(hash [32]byte) {
+	delete(m.archived, hash)
+}
+
+// ArchivedNodeCount returns the number of archived nodes.
+func (m *MemStore) ArchivedNodeCount() int {
+	return len(m.archived)
+}
+
+// GetSnapshot returns the root hash of the snapshot at the given height.
+func (m *MemStore) GetSnapshot(height uint32) ([32]byte, bool) {
+	root, ok := m.snapshots[height]
+	return root, ok
+}
+
+// PutSnapshot sets the root hash of the snapshot at the given height.
+func (m *MemStore) PutSnapshot(height uint32, root [32]byte) {
+	m.snapshots[height] = root
+}
+
+// DelSnapshot removes the snapshot at the given height.
+func (m *MemStore) DelSnapshot(height uint32) {
+	delete(m.snapshots, height)
+}
+
+// SnapshotHeights returns the heights of all snapshots in ascending order.
+func (m *MemStore) SnapshotHeights() []uint32 {
+	heights := make([]uint32, 0, len(m.snapshots))
+	for h := range m.snapshots {
+		heights = append(heights, h)
+	}
+	sortHeights(heights)
+	return heights
+}
+
+func sortHeights(heights []uint32) {
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] < heights[j]
+	})
+}
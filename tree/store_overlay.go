@@ -1,9 +1,33 @@
 package tree
 
+import (
+	"bytes"
+	"sort"
+)
+
 // OverlayStore is an in-memory overlay over an existing Store.
+//
+// Changes are kept in the overlay until they are written down with Flush
+// or dropped with Discard.
+// Overlays can be nested to create savepoints within a pending set of changes.
 type OverlayStore struct {
 	Lower Store
-	diffs map[string]Node
+	diffs map[string]Node // nil value marks a deletion
+}
+
+// NewOverlayStore creates an empty overlay on top of the lower store.
+func NewOverlayStore(lower Store) *OverlayStore {
+	return &OverlayStore{
+		Lower: lower,
+		diffs: make(map[string]Node),
+	}
+}
+
+// Savepoint creates a nested overlay on top of this one.
+// Flushing the savepoint merges its changes into this overlay,
+// discarding it rolls back to the state at the time of the savepoint.
+func (o *OverlayStore) Savepoint() *OverlayStore {
+	return NewOverlayStore(o)
 }
 
 // GetNode reads a node from the overlay or lower store.
@@ -19,11 +43,17 @@ func (o *OverlayStore) GetNode(nbs Nibbles) Node {
 
 // PutNode puts a node in the overlay without affecting the lower store.
 func (o *OverlayStore) PutNode(nbs Nibbles, node Node) {
+	if o.diffs == nil {
+		o.diffs = make(map[string]Node)
+	}
 	o.diffs[string(nbs)] = node
 }
 
 // DelNode marks a deletion in the overlay without affecting the lower store.
 func (o *OverlayStore) DelNode(nbs Nibbles) {
+	if o.diffs == nil {
+		o.diffs = make(map[string]Node)
+	}
 	o.diffs[string(nbs)] = nil
 }
 
@@ -39,3 +69,32 @@ func (o *OverlayStore) Flush() {
 		delete(o.diffs, key)
 	}
 }
+
+// Discard drops all changes in the overlay.
+func (o *OverlayStore) Discard() {
+	o.diffs = make(map[string]Node)
+}
+
+// Len returns the number of changed nodes in the overlay.
+func (o *OverlayStore) Len() int {
+	return len(o.diffs)
+}
+
+// Diff is a pending change to a node.
+type Diff struct {
+	Prefix Nibbles
+	// Node is the new node, or nil for a deletion.
+	Node Node
+}
+
+// Diffs returns the changes in the overlay ordered by path.
+func (o *OverlayStore) Diffs() []Diff {
+	diffs := make([]Diff, 0, len(o.diffs))
+	for key, node := range o.diffs {
+		diffs = append(diffs, Diff{Prefix: Nibbles(key), Node: node})
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].Prefix, diffs[j].Prefix) < 0
+	})
+	return diffs
+}
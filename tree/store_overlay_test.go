@@ -0,0 +1,77 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayStore(t *testing.T) {
+	lower := &PMTree{Store: NewMemStore()}
+	lower.PutEntry(&[20]byte{0x10}, []byte("lower1"))
+	lower.PutEntry(&[20]byte{0x12}, []byte("lower2"))
+	lowerHash := lower.Hash()
+
+	overlay := NewOverlayStore(lower.Store)
+	upper := &PMTree{Store: overlay}
+	upper.PutEntry(&[20]byte{0x11}, []byte("upper1"))
+	upper.PutEntry(&[20]byte{0x12}, nil)
+	upperHash := upper.Hash()
+	assert.NotEqual(t, lowerHash, upperHash)
+
+	// The lower store is untouched until flushing.
+	assert.Equal(t, lowerHash, lower.Hash())
+	assert.Equal(t, []byte("lower2"), lower.GetEntry(&[20]byte{0x12}))
+	assert.Nil(t, lower.GetEntry(&[20]byte{0x11}))
+
+	overlay.Flush()
+	assert.Equal(t, upperHash, lower.Hash())
+	assert.Nil(t, lower.GetEntry(&[20]byte{0x12}))
+	assert.Equal(t, []byte("upper1"), lower.GetEntry(&[20]byte{0x11}))
+}
+
+func TestOverlayStore_Savepoints(t *testing.T) {
+	base := &PMTree{Store: NewMemStore()}
+	base.PutEntry(&[20]byte{0x10}, []byte("base"))
+	baseHash := base.Hash()
+
+	// The zero value is usable.
+	overlay := &OverlayStore{Lower: base.Store}
+	outer := &PMTree{Store: overlay}
+	outer.PutEntry(&[20]byte{0x20}, []byte("outer"))
+	outerHash := outer.Hash()
+
+	savepoint := overlay.Savepoint()
+	inner := &PMTree{Store: savepoint}
+	inner.PutEntry(&[20]byte{0x10}, nil)
+	assert.Nil(t, inner.GetEntry(&[20]byte{0x10}))
+	assert.Equal(t, []byte("base"), outer.GetEntry(&[20]byte{0x10}))
+
+	// Roll back to the savepoint.
+	savepoint.Discard()
+	assert.Equal(t, 0, savepoint.Len())
+	assert.Equal(t, outerHash, inner.Hash())
+
+	// Merge a savepoint into the outer overlay.
+	inner.PutEntry(&[20]byte{0x30}, []byte("inner"))
+	innerHash := inner.Hash()
+	savepoint.Flush()
+	assert.Equal(t, innerHash, outer.Hash())
+	assert.Equal(t, baseHash, base.Hash())
+
+	// Pending changes are ordered by path.
+	diffs := overlay.Diffs()
+	require.Len(t, diffs, overlay.Len())
+	for i := 1; i < len(diffs); i++ {
+		assert.Equal(t, -1, bytes.Compare(diffs[i-1].Prefix, diffs[i].Prefix))
+	}
+	assert.Equal(t, Nibbles{}, diffs[0].Prefix)
+	last := diffs[len(diffs)-1]
+	assert.Equal(t, KeyToNibbles(&[20]byte{0x30}), last.Prefix)
+	assert.Equal(t, []byte("inner"), last.Node.(*Leaf).Value)
+
+	overlay.Discard()
+	assert.Equal(t, baseHash, outer.Hash())
+}
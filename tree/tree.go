@@ -3,6 +3,7 @@ package tree
 import (
 	"bytes"
 	"fmt"
+	"sync"
 )
 
 // Tree is a high-level interface to the state tree.
@@ -14,8 +15,17 @@ type Tree interface {
 
 // PMTree stores the Patricia-Merkle tree on a key-value storage engine.
 // The keys are written in Patricia-order and only one tree revision is kept at a time.
+// Past revisions can be kept in an Archive.
+//
+// Updates can be grouped between Begin and Commit,
+// which defers recomputing hashes until the end of the batch.
 type PMTree struct {
 	Store Store
+	// ParallelHashing recomputes the hashes of the subtrees below the root concurrently.
+	// The store must support concurrent reads.
+	ParallelHashing bool
+
+	batch bool // set between Begin and Commit
 }
 
 // zeroHash is an all zeros Blake2b hash.
@@ -41,10 +51,24 @@ func (t *PMTree) GetEntry(key *[20]byte) []byte {
 // and false if it's already in the required state.
 func (t *PMTree) PutEntry(key *[20]byte, value []byte) bool {
 	changed := t.updateEntry(key, value)
-	t.updateHashes(nil)
+	if !t.batch {
+		t.updateHashes(nil)
+	}
 	return changed
 }
 
+// Begin starts a batch of updates.
+// Until Commit, PutEntry only invalidates the hashes on the modified paths.
+func (t *PMTree) Begin() {
+	t.batch = true
+}
+
+// Commit ends a batch and recomputes all invalidated hashes at once.
+func (t *PMTree) Commit() {
+	t.batch = false
+	t.updateHashes(nil)
+}
+
 func (t *PMTree) updateEntry(key *[20]byte, value []byte) bool {
 	prefix := KeyToNibbles(key)
 	// Check if the entry already exists.
@@ -64,7 +88,11 @@ func (t *PMTree) updateEntry(key *[20]byte, value []byte) bool {
 }
 
 // Hash returns the root hash.
+// During a batch, invalidated hashes are recomputed first.
 func (t *PMTree) Hash() [32]byte {
+	if t.batch {
+		return t.updateHashes(nil)
+	}
 	return t.Store.GetNode(nil).(*Branch).Hash()
 }
 
@@ -112,7 +140,7 @@ func (t *PMTree) update(nodePrefix, prefix Nibbles, value []byte, rootPath []Nod
 		return
 	}
 	// Node prefix matches, but branch reached. Descend.
-	node := t.Store.GetNode(nodePrefix).(*Branch)
+	node := t.Store.GetNode(nodePrefix).(*Branch).clone()
 	targetChild := node.Children[prefix[len(node.Prefix)]]
 	if targetChild.Exists {
 		// Child entry already exists where we would descend to.
@@ -138,6 +166,7 @@ func (t *PMTree) update(nodePrefix, prefix Nibbles, value []byte, rootPath []Nod
 
 // invalidatePath walks the path leaf to root and invalidates the hashes along the way.
 // It also overwrites branch child references as specified in the path.
+// The branches in the path must be copies owned by the caller.
 func (t *PMTree) invalidatePath(prefix Nibbles, path []Node) {
 	// Walk the path of replaced branches up to the root.
 	current := prefix
@@ -147,6 +176,7 @@ func (t *PMTree) invalidatePath(prefix Nibbles, path []Node) {
 		path = path[:len(path)-1]
 		// Set the child to a zero hash.
 		node.PutChild(current[len(node.Prefix):], zeroHash)
+		t.Store.PutNode(node.Prefix, node)
 		current = node.Prefix
 	}
 }
@@ -189,11 +219,15 @@ func (t *PMTree) prune(prefix Nibbles, path []Node) {
 
 // updateHashes iterates the tree in-order and updates any hashes.
 func (t *PMTree) updateHashes(prefix Nibbles) [32]byte {
+	if t.ParallelHashing && len(prefix) == 0 {
+		return t.updateHashesParallel()
+	}
 	node := t.Store.GetNode(prefix)
 	switch n := node.(type) {
 	case *Leaf:
 		return n.Hash()
 	case *Branch:
+		n = n.clone()
 		for i := range n.Children {
 			if !n.Children[i].Exists {
 				continue
@@ -208,3 +242,54 @@ func (t *PMTree) updateHashes(prefix Nibbles) [32]byte {
 		panic(fmt.Sprintf("invalid node type in tree: %T", n))
 	}
 }
+
+// updateHashesParallel updates hashes like updateHashes,
+// but hashes each invalidated child of the root on a separate goroutine.
+// The store is only read concurrently, updated branches are written afterwards.
+func (t *PMTree) updateHashesParallel() [32]byte {
+	root := t.Store.GetNode(nil).(*Branch).clone()
+	var updated [16][]*Branch
+	var wg sync.WaitGroup
+	for i := range root.Children {
+		child := &root.Children[i]
+		if !child.Exists || child.Hash != zeroHash {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, child *Child) {
+			defer wg.Done()
+			child.Hash, updated[i] = t.hashSubtree(child.Suffix, nil)
+		}(i, child)
+	}
+	wg.Wait()
+	for _, branches := range updated {
+		for _, branch := range branches {
+			t.Store.PutNode(branch.Prefix, branch)
+		}
+	}
+	t.Store.PutNode(root.Prefix, root)
+	return root.Hash()
+}
+
+// hashSubtree recomputes the invalidated hashes below a node without writing to the store.
+// Branches with updated hashes are appended to updated.
+func (t *PMTree) hashSubtree(prefix Nibbles, updated []*Branch) ([32]byte, []*Branch) {
+	node := t.Store.GetNode(prefix)
+	switch n := node.(type) {
+	case *Leaf:
+		return n.Hash(), updated
+	case *Branch:
+		n = n.clone()
+		for i := range n.Children {
+			child := &n.Children[i]
+			if !child.Exists || child.Hash != zeroHash {
+				continue
+			}
+			childPrefix := append(n.Prefix[:len(n.Prefix):len(n.Prefix)], child.Suffix...)
+			child.Hash, updated = t.hashSubtree(childPrefix, updated)
+		}
+		return n.Hash(), append(updated, n)
+	default:
+		panic(fmt.Sprintf("invalid node type in tree: %T", n))
+	}
+}
@@ -127,3 +127,99 @@ func TestPMTree_InsertionOrderInvariance(t *testing.T) {
 	empty3Hash := tree.Hash()
 	assert.Equal(t, emptyHash, hex.EncodeToString(empty3Hash[:]))
 }
+
+func BenchmarkPMTree_PutEntryBatch(b *testing.B) {
+	tree := &PMTree{Store: NewMemStore()}
+	tree.Begin()
+	for i := 0; i < b.N; i++ {
+		var addr [20]byte
+		_, _ = rand.Read(addr[:])
+		tree.PutEntry(&addr, []byte("0123"))
+		if i%500 == 499 {
+			tree.Commit()
+			tree.Begin()
+		}
+	}
+	tree.Commit()
+}
+
+func TestPMTree_Batch(t *testing.T) {
+	single := &PMTree{Store: NewMemStore()}
+	batched := &PMTree{Store: NewMemStore()}
+	keys := make([][20]byte, 200)
+	for i := range keys {
+		_, _ = rand.Read(keys[i][:])
+		keys[i][0] &= 0x0F // share prefixes
+	}
+
+	batched.Begin()
+	for i := range keys {
+		single.PutEntry(&keys[i], []byte{byte(i), 1})
+		batched.PutEntry(&keys[i], []byte{byte(i), 1})
+	}
+	// Hash is consistent in the middle of a batch.
+	assert.Equal(t, single.Hash(), batched.Hash())
+	// Overwrite and delete entries, collapsing branches.
+	for i := 0; i < len(keys); i += 2 {
+		single.PutEntry(&keys[i], nil)
+		batched.PutEntry(&keys[i], nil)
+		single.PutEntry(&keys[i+1], []byte{byte(i), 2})
+		batched.PutEntry(&keys[i+1], []byte{byte(i), 2})
+	}
+	batched.Commit()
+	assert.Equal(t, single.Hash(), batched.Hash())
+	for i := range keys {
+		assert.Equal(t, single.GetEntry(&keys[i]), batched.GetEntry(&keys[i]))
+	}
+}
+
+func TestPMTree_ParallelHashing(t *testing.T) {
+	serial := &PMTree{Store: NewMemStore()}
+	parallel := &PMTree{Store: NewMemStore(), ParallelHashing: true}
+	keys := make([][20]byte, 500)
+	for i := range keys {
+		_, _ = rand.Read(keys[i][:])
+	}
+	for _, tree := range []*PMTree{serial, parallel} {
+		tree.Begin()
+		for i := range keys {
+			tree.PutEntry(&keys[i], []byte{byte(i), 1})
+		}
+		tree.Commit()
+	}
+	assert.Equal(t, serial.Hash(), parallel.Hash())
+	// Unbatched updates only touch a single subtree.
+	for i := 0; i < 50; i++ {
+		serial.PutEntry(&keys[i], nil)
+		parallel.PutEntry(&keys[i], nil)
+		assert.Equal(t, serial.Hash(), parallel.Hash())
+	}
+	// Intermediate hashes are written back.
+	proof := parallel.Prove([]*[20]byte{&keys[100]})
+	require.NoError(t, proof.Verify(serial.Hash()))
+}
+
+func benchmarkPMTreeCommit(b *testing.B, parallel bool) {
+	keys := make([][20]byte, 20000)
+	for i := range keys {
+		_, _ = rand.Read(keys[i][:])
+	}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tree := &PMTree{Store: NewMemStore(), ParallelHashing: parallel}
+		tree.Begin()
+		for j := range keys {
+			tree.PutEntry(&keys[j], []byte("0123"))
+		}
+		b.StartTimer()
+		tree.Commit()
+	}
+}
+
+func BenchmarkPMTree_Commit(b *testing.B) {
+	benchmarkPMTreeCommit(b, false)
+}
+
+func BenchmarkPMTree_CommitParallel(b *testing.B) {
+	benchmarkPMTreeCommit(b, true)
+}
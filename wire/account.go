@@ -1,7 +1,10 @@
 package wire
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/bits"
 	"strconv"
 
 	"golang.org/x/crypto/blake2b"
@@ -93,6 +96,10 @@ func AccountEq(a1, a2 Account) bool {
 	}
 }
 
+// ErrIllegalIncoming is returned when sending funds to an existing contract.
+// Contracts only receive funds when they are created.
+var ErrIllegalIncoming = errors.New("contract does not accept incoming transactions")
+
 // An OverspendError means an attempt was made to spend more funds than available.
 type OverspendError struct {
 	Available, Spend uint64
@@ -102,6 +109,28 @@ func (o *OverspendError) Error() string {
 	return fmt.Sprintf("trying to spend %d but only has %d", o.Spend, o.Available)
 }
 
+// spendAmount returns value + fee if it does not exceed the available funds.
+// A sum overflowing uint64 is reported as an overspend of math.MaxUint64.
+func spendAmount(value, fee, available uint64) (uint64, error) {
+	amount, carry := bits.Add64(value, fee, 0)
+	if carry != 0 {
+		return 0, &OverspendError{Available: available, Spend: math.MaxUint64}
+	}
+	if amount > available {
+		return 0, &OverspendError{Available: available, Spend: amount}
+	}
+	return amount, nil
+}
+
+// A MinCapError means a transaction would leave less than the locked amount in a contract.
+type MinCapError struct {
+	MinCap, Balance uint64
+}
+
+func (m *MinCapError) Error() string {
+	return fmt.Sprintf("balance %d would fall below locked amount %d", m.Balance, m.MinCap)
+}
+
 // A BasicAccount is a simple account that can hold funds (balance)
 // and is controlled by an Ed25519 private key.
 // https://nimiq-network.github.io/developer-reference/chapters/accounts-and-contracts.html#basic-account
@@ -128,9 +157,9 @@ func (a *BasicAccount) Init(tx Tx, height uint32, prevBalance uint64) error {
 // ApplyOutgoingTx removes the transaction value and fee from the account.
 func (a *BasicAccount) ApplyOutgoingTx(tx Tx, _ uint32) (Account, error) {
 	value, fee := tx.Amount()
-	amount := value + fee
-	if amount > a.Value {
-		return nil, &OverspendError{Available: a.Value, Spend: amount}
+	amount, err := spendAmount(value, fee, a.Value)
+	if err != nil {
+		return nil, err
 	}
 	return &BasicAccount{Value: a.Value - amount}, nil
 }
@@ -224,20 +253,23 @@ func (c *VestingAccount) Init(tx Tx, _ uint32, balance uint64) error {
 
 // ApplyOutgoingTx removes the transaction value and fee from the account.
 func (c *VestingAccount) ApplyOutgoingTx(tx Tx, height uint32) (Account, error) {
-	unlocked := c.amountUnlocked(height)
-	// Check transaction amount.
 	extTx := tx.(*ExtendedTx)
-	if extTx.Value > unlocked {
-		return nil, &OverspendError{Available: unlocked, Spend: extTx.Value}
+	// Check transaction amount.
+	amount, err := spendAmount(extTx.Value, extTx.Fee, c.Value)
+	if err != nil {
+		return nil, err
+	}
+	newValue := c.Value - amount
+	if minCap := c.minCap(height); newValue < minCap {
+		return nil, &MinCapError{MinCap: minCap, Balance: newValue}
 	}
-	newValue := c.Value - extTx.Value
 	// Verify transaction was signed by contract owner.
 	var proof SignatureProof
 	if err := beserial.UnmarshalFull(extTx.Proof, &proof); err != nil {
 		return nil, fmt.Errorf("in wire.SignatureProof: %w", err)
 	}
 	if proof.SignerAddress() != c.Owner {
-		return nil, fmt.Errorf("invalid signature") // TODO use const error
+		return nil, ErrWrongSigner
 	}
 	// Create copy.
 	updated := new(VestingAccount)
@@ -246,22 +278,35 @@ func (c *VestingAccount) ApplyOutgoingTx(tx Tx, height uint32) (Account, error)
 	return updated, nil
 }
 
-// ApplyIncomingTx adds the transaction value to the account.
-func (c *VestingAccount) ApplyIncomingTx(tx Tx, _ uint32) (Account, error) {
-	panic("not implemented")
+// ApplyIncomingTx rejects the transaction, as vesting contracts cannot receive funds.
+func (c *VestingAccount) ApplyIncomingTx(Tx, uint32) (Account, error) {
+	return nil, ErrIllegalIncoming
 }
 
-func (c *VestingAccount) amountUnlocked(height uint32) uint64 {
-	if height <= c.VestingStart {
-		return 0 // cannot spend funds before vesting start
-	}
+// minCap returns the amount that is still locked at the given height.
+// Every VestingStepBlocks blocks after VestingStart, VestingStepAmount gets unlocked.
+// Before VestingStart, the reference implementation locks more than the total
+// (one step amount per started step until the start), which is mirrored here.
+func (c *VestingAccount) minCap(height uint32) uint64 {
 	if c.VestingStepBlocks == 0 || c.VestingStepAmount == 0 {
-		return c.Value // unlock immediately
+		return 0 // unlock immediately
+	}
+	stepBlocks := uint64(c.VestingStepBlocks)
+	if height < c.VestingStart {
+		steps := (uint64(c.VestingStart-height) + stepBlocks - 1) / stepBlocks
+		hi, locked := bits.Mul64(steps, c.VestingStepAmount)
+		minCap, carry := bits.Add64(c.VestingTotalAmount, locked, 0)
+		if hi != 0 || carry != 0 {
+			return math.MaxUint64
+		}
+		return minCap
 	}
-	// unlock step by step
-	progress := float64(height-c.VestingStart) / float64(c.VestingStepBlocks)
-	unlocked := uint64(progress * float64(c.VestingStepAmount))
-	return unlocked
+	steps := uint64(height-c.VestingStart) / stepBlocks
+	hi, unlocked := bits.Mul64(steps, c.VestingStepAmount)
+	if hi != 0 || unlocked >= c.VestingTotalAmount {
+		return 0
+	}
+	return c.VestingTotalAmount - unlocked
 }
 
 // An HTLCAccount (hashed time-locked contract)
@@ -306,7 +351,9 @@ func (h *HTLCAccount) Init(tx Tx, height uint32, prevBalance uint64) error {
 	if params.HashCount == 0 {
 		return fmt.Errorf("invalid hash count: %d", params.HashCount)
 	}
-	// TODO Argon2d type allowed?
+	if err := checkHTLCAlgorithm(params.Hash.Algorithm); err != nil {
+		return err
+	}
 	*h = HTLCAccount{
 		Value:       prevBalance,
 		Sender:      params.Sender,
@@ -320,89 +367,65 @@ func (h *HTLCAccount) Init(tx Tx, height uint32, prevBalance uint64) error {
 }
 
 // ApplyOutgoingTx removes the transaction value and fee from the account.
+// Signatures and hash pre-images are checked by VerifyTx.
 func (h *HTLCAccount) ApplyOutgoingTx(tx Tx, height uint32) (Account, error) {
-	// TODO use typed errors
 	ext := tx.(*ExtendedTx)
-	if ext.Value > h.Value {
-		return nil, &OverspendError{Available: h.Value, Spend: ext.Value}
-	}
-	// Read proof type.
-	if len(ext.Proof) == 0 {
-		return nil, fmt.Errorf("missing HTLC proof")
+	var proof HTLCProof
+	if err := beserial.UnmarshalFull(ext.Proof, &proof); err != nil {
+		return nil, fmt.Errorf("invalid HTLC proof: %w", err)
 	}
-	proofType := ext.Proof[0]
-	proof := ext.Proof[1:]
-	// TODO enum for proof type
-	switch proofType {
-	case 1:
+	var minCap uint64
+	switch proof.Type {
+	case HTLCRegularTransfer:
 		// Check that the contract has not expired yet.
 		if h.Timeout < height {
-			return nil, fmt.Errorf("HTLC has expired")
-		}
-		// Get proof params.
-		var proofParams struct {
-			Algorithm uint8
-			HashDepth uint8
-			HashRoot  [32]byte
-			PreImage  [32]byte
-			Proof     SignatureProof
-		}
-		if err := beserial.UnmarshalFull(proof, &proofParams); err != nil {
-			return nil, fmt.Errorf("invalid HTLC regular transfer proof: %w", err)
+			return nil, ErrHTLCExpired
 		}
 		// Verify hash root.
-		if proofParams.Algorithm != h.Hash.Algorithm || proofParams.HashRoot != h.Hash.Bytes {
-			return nil, fmt.Errorf("HTLC hash mismatch")
+		if proof.Algorithm != h.Hash.Algorithm || proof.HashRoot != h.Hash.Bytes {
+			return nil, ErrHTLCHashMismatch
 		}
 		// Check transaction signature.
-		if proofParams.Proof.SignerAddress() != h.Recipient {
-			return nil, fmt.Errorf("invalid recipient signature")
-		}
-		// TODO check min cap
-	case 2:
-		// Get proof params.
-		var proofParams struct {
-			RecipientProof SignatureProof
-			SenderProof    SignatureProof
-		}
-		if err := beserial.UnmarshalFull(proof, &proofParams); err != nil {
-			return nil, fmt.Errorf("invalid HTLC early resolve proof: %w", err)
+		if proof.RecipientProof.SignerAddress() != h.Recipient {
+			return nil, fmt.Errorf("HTLC recipient: %w", ErrWrongSigner)
 		}
+		minCap = h.minCap(proof.HashDepth)
+	case HTLCEarlyResolve:
 		// Check that both parties have signed.
-		if proofParams.RecipientProof.SignerAddress() != h.Recipient {
-			return nil, fmt.Errorf("invalid recipient signature")
+		if proof.RecipientProof.SignerAddress() != h.Recipient {
+			return nil, fmt.Errorf("HTLC recipient: %w", ErrWrongSigner)
 		}
-		if proofParams.SenderProof.SignerAddress() != h.Sender {
-			return nil, fmt.Errorf("invalid sender signature")
+		if proof.SenderProof.SignerAddress() != h.Sender {
+			return nil, fmt.Errorf("HTLC sender: %w", ErrWrongSigner)
 		}
-	case 3:
+	case HTLCTimeoutResolve:
 		// Check that the contract has expired.
 		if h.Timeout >= height {
-			return nil, fmt.Errorf("HTLC has not expired yet")
+			return nil, ErrHTLCNotExpired
 		}
-		// Get proof params.
-		var proofParams struct {
-			Proof SignatureProof
+		if proof.SenderProof.SignerAddress() != h.Sender {
+			return nil, fmt.Errorf("HTLC sender: %w", ErrWrongSigner)
 		}
-		if err := beserial.UnmarshalFull(proof, &proofParams); err != nil {
-			return nil, fmt.Errorf("invalid HTLC timeout resolve proof: %w", err)
-		}
-		if proofParams.Proof.SignerAddress() != h.Sender {
-			return nil, fmt.Errorf("invalid sender signature")
-		}
-	default:
-		return nil, fmt.Errorf("unsupported HTLC proof type: %d", proofType)
+	}
+	// Check balance.
+	amount, err := spendAmount(ext.Value, ext.Fee, h.Value)
+	if err != nil {
+		return nil, err
+	}
+	newValue := h.Value - amount
+	if newValue < minCap {
+		return nil, &MinCapError{MinCap: minCap, Balance: newValue}
 	}
 	// Create copy.
 	updated := new(HTLCAccount)
 	*updated = *h
-	updated.Value -= ext.Value
+	updated.Value = newValue
 	return updated, nil
 }
 
-// ApplyIncomingTx adds the transaction value to the account.
-func (h *HTLCAccount) ApplyIncomingTx(tx Tx, _ uint32) (Account, error) {
-	panic("not implemented")
+// ApplyIncomingTx rejects the transaction, as HTLCs cannot receive funds.
+func (h *HTLCAccount) ApplyIncomingTx(Tx, uint32) (Account, error) {
+	return nil, ErrIllegalIncoming
 }
 
 // AccountPruned marks the removal of an account from the state.
@@ -0,0 +1,205 @@
+package wire
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+)
+
+func TestVestingAccount(t *testing.T) {
+	ownerProof := SignatureProof{PublicKey: [32]byte{0x01}}
+	owner := ownerProof.SignerAddress()
+	proof, err := beserial.Marshal(nil, &ownerProof)
+	require.NoError(t, err)
+
+	// vestingData builds creation data with the given trailing fields.
+	vestingData := func(fields ...interface{}) []byte {
+		data := append([]byte(nil), owner[:]...)
+		var buf [8]byte
+		for _, field := range fields {
+			switch v := field.(type) {
+			case uint32:
+				binary.BigEndian.PutUint32(buf[:4], v)
+				data = append(data, buf[:4]...)
+			case uint64:
+				binary.BigEndian.PutUint64(buf[:], v)
+				data = append(data, buf[:]...)
+			}
+		}
+		return data
+	}
+
+	type spend struct {
+		height uint32
+		value  uint64
+		ok     bool
+	}
+	cases := []struct {
+		name     string
+		data     []byte
+		value    uint64
+		expected VestingAccount
+		spends   []spend
+	}{
+		{
+			// Owner and step blocks, the whole value unlocks after one step.
+			name:  "Layout24",
+			data:  vestingData(uint32(100)),
+			value: 1000,
+			expected: VestingAccount{
+				Value:              1000,
+				Owner:              owner,
+				VestingStepBlocks:  100,
+				VestingStepAmount:  1000,
+				VestingTotalAmount: 1000,
+			},
+			spends: []spend{
+				{height: 99, value: 1, ok: false},
+				{height: 100, value: 990, ok: true},
+			},
+		},
+		{
+			// Adds vesting start and step amount, total is the value.
+			name:  "Layout36",
+			data:  vestingData(uint32(50), uint32(10), uint64(300)),
+			value: 1000,
+			expected: VestingAccount{
+				Value:              1000,
+				Owner:              owner,
+				VestingStart:       50,
+				VestingStepBlocks:  10,
+				VestingStepAmount:  300,
+				VestingTotalAmount: 1000,
+			},
+			spends: []spend{
+				{height: 30, value: 1, ok: false},
+				{height: 59, value: 1, ok: false},
+				{height: 60, value: 290, ok: true},
+				{height: 60, value: 291, ok: false},
+				{height: 79, value: 590, ok: true},
+				{height: 80, value: 890, ok: true},
+				{height: 80, value: 891, ok: false},
+				{height: 90, value: 990, ok: true},
+				{height: 90, value: 991, ok: false},
+			},
+		},
+		{
+			// Explicit total amount below the balance keeps the surplus unlocked.
+			name:  "Layout44",
+			data:  vestingData(uint32(0), uint32(1), uint64(1<<40), uint64(1<<62)),
+			value: 1<<62 + 1<<41,
+			expected: VestingAccount{
+				Value:              1<<62 + 1<<41,
+				Owner:              owner,
+				VestingStepBlocks:  1,
+				VestingStepAmount:  1 << 40,
+				VestingTotalAmount: 1 << 62,
+			},
+			spends: []spend{
+				// The surplus is spendable before the first step.
+				{height: 0, value: 1<<41 - 10, ok: true},
+				{height: 0, value: 1<<41 - 9, ok: false},
+				// Exact even where float64 loses precision.
+				{height: 3, value: 1<<41 + 3<<40 - 10, ok: true},
+				{height: 3, value: 1<<41 + 3<<40 - 9, ok: false},
+				{height: 1 << 22, value: 1<<62 + 1<<41 - 10, ok: true},
+			},
+		},
+		{
+			name:  "NoSteps",
+			data:  vestingData(uint32(100), uint32(0), uint64(0), uint64(500)),
+			value: 500,
+			expected: VestingAccount{
+				Value:              500,
+				Owner:              owner,
+				VestingStart:       100,
+				VestingTotalAmount: 500,
+			},
+			spends: []spend{
+				{height: 1, value: 490, ok: true},
+				{height: 1, value: 491, ok: false},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var contract VestingAccount
+			creation := &ExtendedTx{Data: c.data, Value: c.value}
+			require.NoError(t, contract.Init(creation, 1, c.value))
+			assert.Equal(t, c.expected, contract)
+			for _, s := range c.spends {
+				tx := &ExtendedTx{
+					SenderType: AccountVesting,
+					Value:      s.value,
+					Fee:        10,
+					Proof:      proof,
+				}
+				updated, err := contract.ApplyOutgoingTx(tx, s.height)
+				if !s.ok {
+					assert.Error(t, err, "height %d value %d", s.height, s.value)
+					continue
+				}
+				require.NoError(t, err, "height %d value %d", s.height, s.value)
+				assert.Equal(t, c.value-s.value-10, updated.Balance())
+			}
+		})
+	}
+
+	t.Run("InvalidLength", func(t *testing.T) {
+		var contract VestingAccount
+		creation := &ExtendedTx{Data: vestingData(uint32(1), uint32(2))}
+		assert.Error(t, contract.Init(creation, 1, 0))
+	})
+
+	t.Run("WrongSigner", func(t *testing.T) {
+		contract := VestingAccount{Value: 100, Owner: [20]byte{0x02}}
+		tx := &ExtendedTx{Value: 10, Proof: proof}
+		_, err := contract.ApplyOutgoingTx(tx, 1)
+		assert.ErrorIs(t, err, ErrWrongSigner)
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		contract := VestingAccount{Value: 100, Owner: owner}
+		tx := &ExtendedTx{Value: math.MaxUint64 - 5, Fee: 10, Proof: proof}
+		_, err := contract.ApplyOutgoingTx(tx, 1)
+		var overspend *OverspendError
+		require.ErrorAs(t, err, &overspend)
+		assert.Equal(t, uint64(math.MaxUint64), overspend.Spend)
+	})
+
+	t.Run("MinCap", func(t *testing.T) {
+		contract := VestingAccount{
+			Value:              1000,
+			VestingStart:       100,
+			VestingStepBlocks:  10,
+			VestingStepAmount:  100,
+			VestingTotalAmount: 1000,
+		}
+		tx := &ExtendedTx{Value: 101, Proof: proof}
+		_, err := contract.ApplyOutgoingTx(tx, 115)
+		var minCap *MinCapError
+		require.ErrorAs(t, err, &minCap)
+		assert.Equal(t, uint64(900), minCap.MinCap)
+		// Before the start, more than the total is locked.
+		assert.Equal(t, uint64(1200), contract.minCap(85))
+	})
+}
+
+func TestBasicAccount_ApplyOutgoingTx(t *testing.T) {
+	account := &BasicAccount{Value: 100}
+	updated, err := account.ApplyOutgoingTx(&ExtendedTx{Value: 90, Fee: 10}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), updated.Balance())
+
+	var overspend *OverspendError
+	_, err = account.ApplyOutgoingTx(&ExtendedTx{Value: 91, Fee: 10}, 1)
+	require.ErrorAs(t, err, &overspend)
+	assert.Equal(t, uint64(101), overspend.Spend)
+	_, err = account.ApplyOutgoingTx(&ExtendedTx{Value: math.MaxUint64, Fee: 1}, 1)
+	require.ErrorAs(t, err, &overspend)
+	assert.Equal(t, uint64(math.MaxUint64), overspend.Spend)
+}
@@ -1,10 +1,82 @@
 package wire
 
+import (
+	"fmt"
+
+	"terorie.dev/nimiq/beserial"
+)
+
+// AccountsTreeChunk is a consecutive range of accounts tree leaves,
+// with a proof for the last leaf.
 type AccountsTreeChunk struct {
-	Nodes [][]byte `beserial:"len_tag=uint16"`
+	Nodes []AccountsTreeNode `beserial:"len_tag=uint16"`
 	Proof AccountsProof
 }
 
+// AccountsProof is a list of accounts tree nodes
+// from the proven leaves up to the root.
 type AccountsProof struct {
-	Nodes [][]byte `beserial:"len_tag=uint16"`
+	Nodes []AccountsTreeNode `beserial:"len_tag=uint16"`
+}
+
+// AccountsTreeNode is a serialized accounts tree node.
+//
+// Decoding only determines the extent of the node,
+// the contents are interpreted by package tree.
+type AccountsTreeNode []byte
+
+func (n *AccountsTreeNode) UnmarshalBESerial(b []byte) (int, error) {
+	size, err := accountsTreeNodeSize(b)
+	if err != nil {
+		return 0, err
+	}
+	*n = append(AccountsTreeNode(nil), b[:size]...)
+	return size, nil
+}
+
+func (n *AccountsTreeNode) MarshalBESerial(b []byte) ([]byte, error) {
+	return append(b, *n...), nil
+}
+
+func (n *AccountsTreeNode) SizeBESerial() (int, error) {
+	return len(*n), nil
+}
+
+// accountsTreeNodeSize returns the size of the node at the start of b.
+func accountsTreeNodeSize(b []byte) (int, error) {
+	if len(b) < 2 {
+		return 0, fmt.Errorf("reading node prefix: %w", beserial.ErrUnexpectedEOF)
+	}
+	nodeType := b[0]
+	n := 2 + int(b[1])
+	switch nodeType {
+	case 0x00: // branch
+		if len(b) < n+1 {
+			return 0, fmt.Errorf("reading child count: %w", beserial.ErrUnexpectedEOF)
+		}
+		childCount := int(b[n])
+		n++
+		for i := 0; i < childCount; i++ {
+			if len(b) < n+1 {
+				return 0, fmt.Errorf("reading child suffix: %w", beserial.ErrUnexpectedEOF)
+			}
+			n += 1 + int(b[n]) + 32
+		}
+		if len(b) < n {
+			return 0, fmt.Errorf("reading child: %w", beserial.ErrUnexpectedEOF)
+		}
+		return n, nil
+	case 0xFF: // leaf
+		if len(b) < n {
+			return 0, fmt.Errorf("reading node prefix: %w", beserial.ErrUnexpectedEOF)
+		}
+		var account WrapAccount
+		sub, err := account.UnmarshalBESerial(b[n:])
+		if err != nil {
+			return 0, fmt.Errorf("reading account: %w", err)
+		}
+		return n + sub, nil
+	default:
+		return 0, fmt.Errorf("invalid node type: %d", nodeType)
+	}
 }
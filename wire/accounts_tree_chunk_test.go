@@ -0,0 +1,35 @@
+package wire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+)
+
+func TestAccountsTreeChunk(t *testing.T) {
+	leaf := append([]byte{0xFF, 40}, []byte("0000000000000000000000000000000000000000")...)
+	leaf = append(leaf, AccountBasic, 0, 0, 0, 0, 0, 0, 0, 5)
+	branch := []byte{0x00, 0x00, 0x01, 40}
+	branch = append(branch, []byte("0000000000000000000000000000000000000000")...)
+	branch = append(branch, make([]byte, 32)...)
+	chunk := AccountsTreeChunk{
+		Nodes: []AccountsTreeNode{leaf},
+		Proof: AccountsProof{Nodes: []AccountsTreeNode{leaf, branch}},
+	}
+	buf, err := beserial.Marshal(nil, &chunk)
+	require.NoError(t, err)
+	assert.Equal(t, 2+len(leaf)+2+len(leaf)+len(branch), len(buf))
+
+	var decoded AccountsTreeChunk
+	require.NoError(t, beserial.UnmarshalFull(buf, &decoded))
+	assert.Equal(t, chunk, decoded)
+
+	// Truncated nodes are rejected.
+	for _, node := range [][]byte{leaf, branch} {
+		var n AccountsTreeNode
+		_, err := n.UnmarshalBESerial(node[:len(node)-1])
+		assert.Error(t, err)
+	}
+}
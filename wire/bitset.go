@@ -41,3 +41,7 @@ func (bs *BitSet) SizeBESerial() (n int, err error) {
 func (bs *BitSet) bit(i uint8) bool {
 	return bs.Bits[i/8]&(0x80>>(i%8)) != 0
 }
+
+func (bs *BitSet) set(i uint8) {
+	bs.Bits[i/8] |= 0x80 >> (i % 8)
+}
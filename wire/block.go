@@ -1,10 +1,14 @@
 package wire
 
 import (
+	"errors"
 	"fmt"
-	"math/bits"
+	"math/big"
 
+	"golang.org/x/crypto/blake2b"
+	"terorie.dev/nimiq/argon2d"
 	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/policy"
 )
 
 // A Block in the block chain!
@@ -27,6 +31,94 @@ type BlockHeader struct {
 	Nonce         uint32
 }
 
+// Block verification errors.
+var (
+	ErrInvalidTarget    = errors.New("block target out of range")
+	ErrInvalidPoW       = errors.New("block hash above target")
+	ErrMissingBody      = errors.New("block body missing")
+	ErrBodyHashMismatch = errors.New("block body hash mismatch")
+)
+
+// UnmarshalBESerial decodes the block and resolves interlink hashes
+// that refer to the previous block.
+func (b *Block) UnmarshalBESerial(buf []byte) (n int, err error) {
+	type plainBlock Block
+	n, err = beserial.Unmarshal(buf, (*plainBlock)(b))
+	if err != nil {
+		return
+	}
+	b.Interlink.setPrevHash(&b.Header.PrevHash)
+	return
+}
+
+// VerifyBodyHash checks that the body matches the body hash in the header.
+func (b *Block) VerifyBodyHash() error {
+	if b.Body == nil {
+		return ErrMissingBody
+	}
+	if b.Body.Hash() != b.Header.BodyHash {
+		return ErrBodyHashMismatch
+	}
+	return nil
+}
+
+// Hash returns the Blake2b hash of the serialized header.
+// It identifies the block and is referenced by PrevHash of the next block.
+func (h *BlockHeader) Hash() [32]byte {
+	return blake2b.Sum256(h.serialize())
+}
+
+// PowHash returns the Argon2d hash of the serialized header,
+// which is compared against the target.
+func (h *BlockHeader) PowHash() [32]byte {
+	return argon2d.Sum(h.serialize())
+}
+
+// Target returns the proof-of-work target decoded from NBits.
+func (h *BlockHeader) Target() big.Int {
+	return CompactToTarget(h.NBits)
+}
+
+// VerifyPoW checks that the proof-of-work hash is not above the target.
+func (h *BlockHeader) VerifyPoW() error {
+	if _, err := h.checkedTarget(); err != nil {
+		return err
+	}
+	pow := h.PowHash()
+	return h.VerifyPowHash(&pow)
+}
+
+// VerifyPowHash checks a proof-of-work hash returned by PowHash against the target,
+// for callers keeping the hash to build interlinks.
+func (h *BlockHeader) VerifyPowHash(pow *[32]byte) error {
+	target, err := h.checkedTarget()
+	if err != nil {
+		return err
+	}
+	var powNum big.Int
+	powNum.SetBytes(pow[:])
+	if powNum.Cmp(&target) > 0 {
+		return ErrInvalidPoW
+	}
+	return nil
+}
+
+func (h *BlockHeader) checkedTarget() (big.Int, error) {
+	target := h.Target()
+	if target.Sign() <= 0 || target.Cmp(&policy.BlockTargetMax) > 0 {
+		return target, ErrInvalidTarget
+	}
+	return target, nil
+}
+
+func (h *BlockHeader) serialize() []byte {
+	buf, err := beserial.Marshal(make([]byte, 0, 146), h)
+	if err != nil {
+		panic("failed to serialize block header: " + err.Error())
+	}
+	return buf
+}
+
 // BlockBody holds the transactions in a block.
 type BlockBody struct {
 	MinerAddr [20]byte
@@ -35,7 +127,41 @@ type BlockBody struct {
 	Pruned    []AccountPruned `beserial:"len_tag=uint16"`
 }
 
+// MerkleLeaves returns the leaf hashes of the body Merkle tree:
+// The miner address, extra data, transactions and pruned accounts, in that order.
+func (b *BlockBody) MerkleLeaves() [][32]byte {
+	leaves := make([][32]byte, 0, 2+len(b.Txs)+len(b.Pruned))
+	leaves = append(leaves, blake2b.Sum256(b.MinerAddr[:]))
+	leaves = append(leaves, blake2b.Sum256(b.ExtraData))
+	for _, tx := range b.Txs {
+		leaves = append(leaves, tx.Hash())
+	}
+	for i := range b.Pruned {
+		buf, err := beserial.Marshal(nil, &b.Pruned[i])
+		if err != nil {
+			panic("failed to serialize pruned account: " + err.Error())
+		}
+		leaves = append(leaves, blake2b.Sum256(buf))
+	}
+	return leaves
+}
+
+// Hash returns the Merkle root of the body, which is committed to in the header.
+func (b *BlockBody) Hash() [32]byte {
+	return MerkleRoot(b.MerkleLeaves())
+}
+
+// TxProof returns the Merkle path proving the inclusion of the transaction
+// at the given index in the body hash.
+func (b *BlockBody) TxProof(index int) MerklePath {
+	return NewMerklePath(b.MerkleLeaves(), 2+index)
+}
+
 // BlockInterlink builds the NIPoPoW proofs.
+//
+// Consecutive equal hashes are compressed:
+// A set repeat bit means the hash equals the previous one,
+// or PrevHash (the hash of the previous block) for the first hash.
 type BlockInterlink struct {
 	Repeats    BitSet
 	Hashes     []*[32]byte // pointers to Compressed
@@ -44,6 +170,7 @@ type BlockInterlink struct {
 }
 
 // UnmarshalBESerial implements the interlink decoding algorithm.
+// Leading repeated hashes are only known after calling setPrevHash.
 func (il *BlockInterlink) UnmarshalBESerial(b []byte) (n int, err error) {
 	orig := b
 	// Read repeat bits
@@ -54,10 +181,12 @@ func (il *BlockInterlink) UnmarshalBESerial(b []byte) (n int, err error) {
 	b = b[n:]
 	// Iterate through bit stream and count bits (number of unique hashes)
 	il.Hashes = make([]*[32]byte, il.Repeats.Len)
-	hash := &il.PrevHash
+	hash := new([32]byte) // placeholder for PrevHash
 	var compressedCount int
-	for _, repeatBits := range il.Repeats.Bits {
-		compressedCount += 8 - bits.OnesCount8(repeatBits)
+	for i := uint8(0); i < il.Repeats.Len; i++ {
+		if !il.Repeats.bit(i) {
+			compressedCount++
+		}
 	}
 	// Allocate space for unique hashes
 	il.Compressed = make([][32]byte, compressedCount)
@@ -74,8 +203,8 @@ func (il *BlockInterlink) UnmarshalBESerial(b []byte) (n int, err error) {
 			if len(b) < len(hash) {
 				return 0, fmt.Errorf("failed to read hash: %w", beserial.ErrUnexpectedEOF)
 			}
-			b = b[len(hash):]
 			copy(hash[:], b)
+			b = b[len(hash):]
 		}
 		// Save pointer to last hash
 		il.Hashes[i] = hash
@@ -83,6 +212,15 @@ func (il *BlockInterlink) UnmarshalBESerial(b []byte) (n int, err error) {
 	return len(orig) - len(b), nil
 }
 
+// setPrevHash sets the hash of the previous block,
+// which leading repeated hashes refer to.
+func (il *BlockInterlink) setPrevHash(prevHash *[32]byte) {
+	il.PrevHash = *prevHash
+	if len(il.Hashes) > 0 && il.Repeats.bit(0) {
+		*il.Hashes[0] = *prevHash
+	}
+}
+
 func (il *BlockInterlink) MarshalBESerial(b []byte) ([]byte, error) {
 	var err error
 	b, err = il.Repeats.MarshalBESerial(b)
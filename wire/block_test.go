@@ -0,0 +1,76 @@
+package wire
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+)
+
+// testGenesisHeader returns the header of the main net genesis block.
+func testGenesisHeader(t *testing.T) *BlockHeader {
+	buf, err := hex.DecodeString("0001" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"7cda9a7fdf06655905ae5dbd9c535451471b078fa6f3df0e287e5b0fb47a573a" +
+		"1fefd44f1fa97185fda21e957545c97dc7643fa7e4efdd86e0aa4244d1e0bc5c" +
+		"1f010000" + "00000001" + "5ad23a98" + "000219d9")
+	require.NoError(t, err)
+	header := new(BlockHeader)
+	require.NoError(t, beserial.UnmarshalFull(buf, header))
+	return header
+}
+
+func TestBlockHeader_Hash(t *testing.T) {
+	header := testGenesisHeader(t)
+	hash := header.Hash()
+	assert.Equal(t,
+		"264aaf8a4f9828a76c550635da078eb466306a189fcc03710bee9f649c869d12",
+		hex.EncodeToString(hash[:]))
+	pow := header.PowHash()
+	assert.Equal(t,
+		"000087dccfcb8625a84c821887c5c515f92f5078501bf49369f8993657cdc034",
+		hex.EncodeToString(pow[:]))
+}
+
+func TestBlockHeader_VerifyPoW(t *testing.T) {
+	header := testGenesisHeader(t)
+	assert.NoError(t, header.VerifyPoW())
+	pow := header.PowHash()
+	assert.NoError(t, header.VerifyPowHash(&pow))
+
+	// Lower the target below the hash.
+	header.NBits = 0x1e010000
+	assert.ErrorIs(t, header.VerifyPoW(), ErrInvalidPoW)
+	assert.ErrorIs(t, header.VerifyPowHash(&pow), ErrInvalidPoW)
+
+	// Target above the maximum.
+	header.NBits = 0x20010000
+	assert.ErrorIs(t, header.VerifyPoW(), ErrInvalidTarget)
+	header.NBits = 0
+	assert.ErrorIs(t, header.VerifyPoW(), ErrInvalidTarget)
+}
+
+func TestBlock_VerifyBodyHash(t *testing.T) {
+	tx := &BasicTx{Recipient: [20]byte{0x01}, Value: 10}
+	block := &Block{Body: &BlockBody{
+		MinerAddr: [20]byte{0x02},
+		ExtraData: []byte("extra"),
+		Txs:       []WrapTx{{Tx: tx}},
+		Pruned:    []AccountPruned{{Account: WrapAccount{Account: &BasicAccount{}}}},
+	}}
+	assert.ErrorIs(t, block.VerifyBodyHash(), ErrBodyHashMismatch)
+	block.Header.BodyHash = block.Body.Hash()
+	assert.NoError(t, block.VerifyBodyHash())
+
+	// Transactions can be proven against the body hash.
+	proof := block.Body.TxProof(0)
+	assert.Equal(t, block.Header.BodyHash, proof.ComputeRootFromHash(tx.Hash()))
+
+	tx.Value++
+	assert.ErrorIs(t, block.VerifyBodyHash(), ErrBodyHashMismatch)
+	block.Body = nil
+	assert.ErrorIs(t, block.VerifyBodyHash(), ErrMissingBody)
+}
@@ -1,6 +1,11 @@
 package wire
 
 import (
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+	"terorie.dev/nimiq/argon2d"
 	"terorie.dev/nimiq/beserial"
 )
 
@@ -13,9 +18,31 @@ type Hash struct {
 // Supported hash types.
 const (
 	HashBlake2b = 1
+	HashArgon2d = 2
 	HashSha256  = 3
 )
 
+// An UnsupportedHashError is returned for unknown hash algorithms.
+type UnsupportedHashError uint8
+
+func (u UnsupportedHashError) Error() string {
+	return fmt.Sprintf("unsupported hash algorithm: %d", uint8(u))
+}
+
+// ComputeHash hashes data with the given algorithm.
+func ComputeHash(algorithm uint8, data []byte) ([32]byte, error) {
+	switch algorithm {
+	case HashBlake2b:
+		return blake2b.Sum256(data), nil
+	case HashArgon2d:
+		return argon2d.Sum(data), nil
+	case HashSha256:
+		return sha256.Sum256(data), nil
+	default:
+		return [32]byte{}, UnsupportedHashError(algorithm)
+	}
+}
+
 func (h *Hash) UnmarshalBESerial(b []byte) (n int, err error) {
 	if len(b) < 1 {
 		return 0, beserial.ErrUnexpectedEOF
@@ -0,0 +1,196 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"terorie.dev/nimiq/beserial"
+)
+
+// HTLC proof types.
+const (
+	HTLCRegularTransfer = uint8(1)
+	HTLCEarlyResolve    = uint8(2)
+	HTLCTimeoutResolve  = uint8(3)
+)
+
+// HTLC errors.
+var (
+	ErrHTLCExpired      = errors.New("HTLC has expired")
+	ErrHTLCNotExpired   = errors.New("HTLC has not expired yet")
+	ErrHTLCHashMismatch = errors.New("HTLC hash root does not match contract")
+	ErrHTLCPreImage     = errors.New("HTLC pre-image does not match hash root")
+)
+
+// HTLCProof is the proof of a transaction spending from an HTLC.
+// The set of fields used depends on the proof type.
+type HTLCProof struct {
+	Type uint8
+	// Hash pre-image, for regular transfers.
+	Algorithm uint8
+	HashDepth uint8
+	HashRoot  [32]byte
+	PreImage  [32]byte
+	// RecipientProof is used by regular transfers and early resolves.
+	RecipientProof SignatureProof
+	// SenderProof is used by early resolves and timeout resolves.
+	SenderProof SignatureProof
+}
+
+// htlcRegularTransfer, htlcEarlyResolve and htlcTimeoutResolve
+// are the encodings of HTLCProof excluding the type prefix.
+type htlcRegularTransfer struct {
+	Algorithm uint8
+	HashDepth uint8
+	HashRoot  [32]byte
+	PreImage  [32]byte
+	Proof     SignatureProof
+}
+
+type htlcEarlyResolve struct {
+	RecipientProof SignatureProof
+	SenderProof    SignatureProof
+}
+
+type htlcTimeoutResolve struct {
+	Proof SignatureProof
+}
+
+func (p *HTLCProof) UnmarshalBESerial(b []byte) (n int, err error) {
+	if len(b) < 1 {
+		return 0, beserial.ErrUnexpectedEOF
+	}
+	*p = HTLCProof{Type: b[0]}
+	var sub int
+	switch p.Type {
+	case HTLCRegularTransfer:
+		var params htlcRegularTransfer
+		sub, err = beserial.Unmarshal(b[1:], &params)
+		p.Algorithm = params.Algorithm
+		p.HashDepth = params.HashDepth
+		p.HashRoot = params.HashRoot
+		p.PreImage = params.PreImage
+		p.RecipientProof = params.Proof
+	case HTLCEarlyResolve:
+		var params htlcEarlyResolve
+		sub, err = beserial.Unmarshal(b[1:], &params)
+		p.RecipientProof = params.RecipientProof
+		p.SenderProof = params.SenderProof
+	case HTLCTimeoutResolve:
+		var params htlcTimeoutResolve
+		sub, err = beserial.Unmarshal(b[1:], &params)
+		p.SenderProof = params.Proof
+	default:
+		return 0, fmt.Errorf("unsupported HTLC proof type: %d", p.Type)
+	}
+	return 1 + sub, err
+}
+
+func (p *HTLCProof) MarshalBESerial(b []byte) ([]byte, error) {
+	b = append(b, p.Type)
+	params, err := p.params()
+	if err != nil {
+		return nil, err
+	}
+	return beserial.Marshal(b, params)
+}
+
+func (p *HTLCProof) SizeBESerial() (int, error) {
+	params, err := p.params()
+	if err != nil {
+		return 0, err
+	}
+	n, err := beserial.Size(params)
+	return 1 + n, err
+}
+
+// params returns the type-specific part of the proof.
+func (p *HTLCProof) params() (interface{}, error) {
+	switch p.Type {
+	case HTLCRegularTransfer:
+		return &htlcRegularTransfer{
+			Algorithm: p.Algorithm,
+			HashDepth: p.HashDepth,
+			HashRoot:  p.HashRoot,
+			PreImage:  p.PreImage,
+			Proof:     p.RecipientProof,
+		}, nil
+	case HTLCEarlyResolve:
+		return &htlcEarlyResolve{
+			RecipientProof: p.RecipientProof,
+			SenderProof:    p.SenderProof,
+		}, nil
+	case HTLCTimeoutResolve:
+		return &htlcTimeoutResolve{
+			Proof: p.SenderProof,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported HTLC proof type: %d", p.Type)
+	}
+}
+
+// Verify checks the signatures contained in the proof against the message.
+// For regular transfers, it also checks that the pre-image
+// hashed HashDepth times results in the hash root.
+// Whether the hash root belongs to the HTLC is checked when applying the transaction.
+func (p *HTLCProof) Verify(msg []byte) error {
+	switch p.Type {
+	case HTLCRegularTransfer:
+		if err := p.VerifyPreImage(); err != nil {
+			return err
+		}
+		return p.RecipientProof.Verify(msg)
+	case HTLCEarlyResolve:
+		if err := p.RecipientProof.Verify(msg); err != nil {
+			return err
+		}
+		return p.SenderProof.Verify(msg)
+	case HTLCTimeoutResolve:
+		return p.SenderProof.Verify(msg)
+	default:
+		return fmt.Errorf("unsupported HTLC proof type: %d", p.Type)
+	}
+}
+
+// VerifyPreImage checks that the pre-image hashed HashDepth times results in the hash root.
+// Algorithms not allowed in HTLCs are rejected before hashing.
+func (p *HTLCProof) VerifyPreImage() error {
+	if err := checkHTLCAlgorithm(p.Algorithm); err != nil {
+		return err
+	}
+	hash := p.PreImage
+	for i := uint8(0); i < p.HashDepth; i++ {
+		var err error
+		hash, err = ComputeHash(p.Algorithm, hash[:])
+		if err != nil {
+			return err
+		}
+	}
+	if hash != p.HashRoot {
+		return ErrHTLCPreImage
+	}
+	return nil
+}
+
+// checkHTLCAlgorithm rejects hash algorithms HTLCs cannot use.
+// The reference implementation blacklists Argon2d for HTLCs,
+// which would also make verifying deep pre-images expensive.
+func checkHTLCAlgorithm(algorithm uint8) error {
+	if algorithm != HashBlake2b && algorithm != HashSha256 {
+		return UnsupportedHashError(algorithm)
+	}
+	return nil
+}
+
+// minCap returns the amount that must remain in the HTLC after a regular transfer.
+// Each hash step revealed by the recipient unlocks an equal share of the total amount.
+// Revealing a pre-image deeper than the hash count unlocks everything.
+func (h *HTLCAccount) minCap(hashDepth uint8) uint64 {
+	// Float arithmetic matches the reference implementation.
+	minCap := math.Floor((1 - float64(hashDepth)/float64(h.HashCount)) * float64(h.TotalAmount))
+	if minCap <= 0 {
+		return 0
+	}
+	return uint64(minCap)
+}
@@ -0,0 +1,205 @@
+package wire
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+)
+
+func TestHTLCProof_VerifyPreImage(t *testing.T) {
+	for _, algorithm := range []uint8{HashBlake2b, HashSha256} {
+		proof := HTLCProof{
+			Type:      HTLCRegularTransfer,
+			Algorithm: algorithm,
+			HashDepth: 3,
+			PreImage:  [32]byte{0x42},
+		}
+		proof.HashRoot = proof.PreImage
+		for i := 0; i < 3; i++ {
+			var err error
+			proof.HashRoot, err = ComputeHash(algorithm, proof.HashRoot[:])
+			require.NoError(t, err)
+		}
+		assert.NoError(t, proof.VerifyPreImage(), "algorithm %d", algorithm)
+
+		proof.HashDepth = 2
+		assert.ErrorIs(t, proof.VerifyPreImage(), ErrHTLCPreImage, "algorithm %d", algorithm)
+	}
+
+	for _, algorithm := range []uint8{HashArgon2d, 4} {
+		proof := HTLCProof{Algorithm: algorithm, HashDepth: 255}
+		var unsupported UnsupportedHashError
+		assert.ErrorAs(t, proof.VerifyPreImage(), &unsupported, "algorithm %d", algorithm)
+	}
+}
+
+func TestHTLCAccount_ApplyOutgoingTx(t *testing.T) {
+	senderProof := SignatureProof{PublicKey: [32]byte{0x01}}
+	recipientProof := SignatureProof{PublicKey: [32]byte{0x02}}
+	htlc := &HTLCAccount{
+		Value:       1000,
+		Sender:      senderProof.SignerAddress(),
+		Recipient:   recipientProof.SignerAddress(),
+		Hash:        Hash{Algorithm: HashSha256, Bytes: [32]byte{0xAA}},
+		HashCount:   4,
+		Timeout:     100,
+		TotalAmount: 1000,
+	}
+	regular := func(depth uint8) HTLCProof {
+		return HTLCProof{
+			Type:           HTLCRegularTransfer,
+			Algorithm:      HashSha256,
+			HashDepth:      depth,
+			HashRoot:       htlc.Hash.Bytes,
+			RecipientProof: recipientProof,
+		}
+	}
+	cases := []struct {
+		name   string
+		proof  HTLCProof
+		value  uint64
+		height uint32
+		after  uint64
+		check  func(t *testing.T, err error)
+	}{
+		{
+			name:   "PartialTransfer",
+			proof:  regular(1),
+			value:  240,
+			height: 100,
+			after:  750,
+		},
+		{
+			name:   "BelowMinCap",
+			proof:  regular(1),
+			value:  241,
+			height: 100,
+			check: func(t *testing.T, err error) {
+				var minCap *MinCapError
+				require.ErrorAs(t, err, &minCap)
+				assert.Equal(t, uint64(750), minCap.MinCap)
+				assert.Equal(t, uint64(749), minCap.Balance)
+			},
+		},
+		{
+			name:   "FullTransfer",
+			proof:  regular(4),
+			value:  990,
+			height: 100,
+			after:  0,
+		},
+		{
+			name:   "BeyondHashCount",
+			proof:  regular(5),
+			value:  990,
+			height: 100,
+			after:  0,
+		},
+		{
+			name:   "Overspend",
+			proof:  regular(4),
+			value:  991,
+			height: 100,
+			check: func(t *testing.T, err error) {
+				var overspend *OverspendError
+				assert.ErrorAs(t, err, &overspend)
+			},
+		},
+		{
+			name:   "Overflow",
+			proof:  regular(4),
+			value:  math.MaxUint64 - 5,
+			height: 100,
+			check: func(t *testing.T, err error) {
+				var overspend *OverspendError
+				require.ErrorAs(t, err, &overspend)
+				assert.Equal(t, uint64(math.MaxUint64), overspend.Spend)
+			},
+		},
+		{
+			name:   "Expired",
+			proof:  regular(4),
+			value:  10,
+			height: 101,
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrHTLCExpired)
+			},
+		},
+		{
+			name: "HashMismatch",
+			proof: func() HTLCProof {
+				p := regular(4)
+				p.Algorithm = HashBlake2b
+				return p
+			}(),
+			value:  10,
+			height: 100,
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrHTLCHashMismatch)
+			},
+		},
+		{
+			name: "WrongRecipient",
+			proof: func() HTLCProof {
+				p := regular(4)
+				p.RecipientProof = senderProof
+				return p
+			}(),
+			value:  10,
+			height: 100,
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrWrongSigner)
+			},
+		},
+		{
+			name: "EarlyResolve",
+			proof: HTLCProof{
+				Type:           HTLCEarlyResolve,
+				RecipientProof: recipientProof,
+				SenderProof:    senderProof,
+			},
+			value:  990,
+			height: 50,
+			after:  0,
+		},
+		{
+			name:   "TimeoutResolve",
+			proof:  HTLCProof{Type: HTLCTimeoutResolve, SenderProof: senderProof},
+			value:  500,
+			height: 101,
+			after:  490,
+		},
+		{
+			name:   "NotExpired",
+			proof:  HTLCProof{Type: HTLCTimeoutResolve, SenderProof: senderProof},
+			value:  500,
+			height: 100,
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrHTLCNotExpired)
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			proof, err := beserial.Marshal(nil, &c.proof)
+			require.NoError(t, err)
+			tx := &ExtendedTx{
+				SenderType: AccountHTLC,
+				Value:      c.value,
+				Fee:        10,
+				Proof:      proof,
+			}
+			updated, err := htlc.ApplyOutgoingTx(tx, c.height)
+			if c.check != nil {
+				c.check(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.after, updated.Balance())
+			assert.Equal(t, uint64(1000), htlc.Value, "original must not be modified")
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package wire
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"golang.org/x/crypto/blake2b"
+	"terorie.dev/nimiq/policy"
+)
+
+// Interlink verification errors.
+var (
+	ErrInterlinkHashMismatch = errors.New("block interlink hash mismatch")
+	ErrInvalidInterlink      = errors.New("block interlink does not match previous block")
+)
+
+// BlockVersion1 is the version of all blocks on the Nimiq 1.0 chain.
+const BlockVersion1 = 1
+
+// NewBlockInterlink creates a compressed interlink from a list of hashes.
+// prevHash is the hash of the previous block.
+func NewBlockInterlink(hashes [][32]byte, prevHash *[32]byte) *BlockInterlink {
+	il := &BlockInterlink{
+		Repeats: BitSet{
+			Len:  uint8(len(hashes)),
+			Bits: make([]byte, (len(hashes)+7)/8),
+		},
+		Hashes:   make([]*[32]byte, len(hashes)),
+		PrevHash: *prevHash,
+	}
+	last := prevHash
+	for i := range hashes {
+		if hashes[i] == *last {
+			il.Repeats.set(uint8(i))
+		} else {
+			il.Compressed = append(il.Compressed, hashes[i])
+			last = &hashes[i]
+		}
+	}
+	// Resolve pointers after all compressed hashes were appended.
+	shared := new([32]byte)
+	*shared = *prevHash
+	compressedIndex := -1
+	for i := range il.Hashes {
+		if !il.Repeats.bit(uint8(i)) {
+			compressedIndex++
+		}
+		if compressedIndex < 0 {
+			il.Hashes[i] = shared
+		} else {
+			il.Hashes[i] = &il.Compressed[compressedIndex]
+		}
+	}
+	return il
+}
+
+// Equal checks whether two interlinks contain the same hashes.
+func (il *BlockInterlink) Equal(other *BlockInterlink) bool {
+	if len(il.Hashes) != len(other.Hashes) {
+		return false
+	}
+	for i := range il.Hashes {
+		if *il.Hashes[i] != *other.Hashes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns the interlink hash committed to in the block header.
+//
+// The Merkle root covers the repeat bits, the genesis hash of the network
+// and the compressed hashes.
+func (il *BlockInterlink) Hash(genesisHash *[32]byte) [32]byte {
+	leaves := make([][32]byte, 0, 2+len(il.Compressed))
+	leaves = append(leaves, blake2b.Sum256(il.Repeats.Bits))
+	leaves = append(leaves, *genesisHash)
+	leaves = append(leaves, il.Compressed...)
+	return MerkleRoot(leaves)
+}
+
+// VerifyInterlinkHash checks that the interlink matches the interlink hash in the header.
+func (b *Block) VerifyInterlinkHash(genesisHash *[32]byte) error {
+	if b.Interlink.Hash(genesisHash) != b.Header.InterlinkHash {
+		return ErrInterlinkHashMismatch
+	}
+	return nil
+}
+
+// VerifyInterlink checks that the interlink of the block was built from the previous block.
+// prevPow is the proof-of-work hash of the previous block.
+func (b *Block) VerifyInterlink(prev *Block, prevPow *[32]byte) error {
+	expected := prev.NextInterlink(prevPow, b.Header.Target())
+	if !b.Interlink.Equal(expected) {
+		return ErrInvalidInterlink
+	}
+	return nil
+}
+
+// NextInterlink builds the interlink of the block following this block.
+//
+// The interlink references the latest block reaching each depth below the target,
+// where the depth is the number of bits its proof-of-work hash is below the maximum target.
+// Blocks are referenced by their block hash, so references to this block
+// are compressed as repeats of the previous hash.
+// Interlinks are relative to the target of the block, so nextTarget is required.
+// pow is the proof-of-work hash of this block, which is expensive to compute.
+func (b *Block) NextInterlink(pow *[32]byte, nextTarget big.Int) *BlockInterlink {
+	var powNum big.Int
+	powNum.SetBytes(pow[:])
+	hash := b.Header.Hash()
+	thisTarget := b.Header.Target()
+	hashes := nextInterlinkHashes(
+		hash,
+		targetDepth(&powNum),
+		targetDepth(&thisTarget),
+		targetDepth(&nextTarget),
+		b.Interlink.Hashes,
+	)
+	return NewBlockInterlink(hashes, &hash)
+}
+
+// nextInterlinkHashes implements the interlink update rule.
+func nextInterlinkHashes(ref [32]byte, powDepth, thisTargetDepth, nextTargetDepth int, interlink []*[32]byte) [][32]byte {
+	// Number of interlink entries this block occupies.
+	occurrences := powDepth - nextTargetDepth + 1
+	if occurrences < 0 {
+		occurrences = 0
+	}
+	hashes := make([][32]byte, 0, occurrences+len(interlink))
+	for i := 0; i < occurrences; i++ {
+		hashes = append(hashes, ref)
+	}
+	// Entries replaced by this block, or moved out of range by a changing target are dropped.
+	offset := occurrences + nextTargetDepth - thisTargetDepth
+	if offset < 0 {
+		offset = 0
+	}
+	for i := offset; i < len(interlink); i++ {
+		hashes = append(hashes, *interlink[i])
+	}
+	return hashes
+}
+
+// targetDepth returns the number of bits the target is below the maximum target.
+// Like in the reference implementation, the bit length is computed with float64 precision.
+func targetDepth(target *big.Int) int {
+	return targetHeight(&policy.BlockTargetMax) - targetHeight(target)
+}
+
+func targetHeight(target *big.Int) int {
+	if target.Sign() <= 0 {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(target).Float64()
+	return int(math.Ceil(math.Log2(f)))
+}
@@ -0,0 +1,125 @@
+package wire
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+	"terorie.dev/nimiq/policy"
+)
+
+func TestBlockInterlink_Serialization(t *testing.T) {
+	prev := [32]byte{0x01}
+	a := [32]byte{0x0a}
+	b := [32]byte{0x0b}
+	hashes := [][32]byte{prev, prev, a, a, b, b, b, b, a}
+	block := &Block{
+		Header:    BlockHeader{Version: BlockVersion1, PrevHash: prev},
+		Interlink: *NewBlockInterlink(hashes, &prev),
+	}
+	assert.Equal(t, [][32]byte{a, b, a}, block.Interlink.Compressed)
+	assert.Equal(t, BitSet{Len: 9, Bits: []byte{0xD7, 0x00}}, block.Interlink.Repeats)
+
+	buf, err := beserial.Marshal(nil, block)
+	require.NoError(t, err)
+	size, err := beserial.Size(block)
+	require.NoError(t, err)
+	assert.Len(t, buf, size)
+
+	var decoded Block
+	require.NoError(t, beserial.UnmarshalFull(buf, &decoded))
+	require.Len(t, decoded.Interlink.Hashes, len(hashes))
+	for i := range hashes {
+		assert.Equal(t, hashes[i], *decoded.Interlink.Hashes[i], "hash %d", i)
+	}
+	assert.Equal(t, block.Interlink.Compressed, decoded.Interlink.Compressed)
+	assert.True(t, block.Interlink.Equal(&decoded.Interlink))
+	genesisHash := [32]byte{0xff}
+	assert.Equal(t, block.Interlink.Hash(&genesisHash), decoded.Interlink.Hash(&genesisHash))
+}
+
+func TestTargetDepth(t *testing.T) {
+	assert.Equal(t, 0, targetDepth(&policy.BlockTargetMax))
+	var target big.Int
+	target.Rsh(&policy.BlockTargetMax, 1)
+	assert.Equal(t, 1, targetDepth(&target))
+	// The bit length is computed with float64 precision.
+	target.Add(&target, big.NewInt(1))
+	assert.Equal(t, 1, targetDepth(&target))
+	target.Add(&target, new(big.Int).Lsh(big.NewInt(1), 200))
+	assert.Equal(t, 0, targetDepth(&target))
+	target.Rsh(&policy.BlockTargetMax, 20)
+	assert.Equal(t, 20, targetDepth(&target))
+}
+
+func TestNextInterlinkHashes(t *testing.T) {
+	ref := [32]byte{0xee}
+	a, b, c := [32]byte{0x0a}, [32]byte{0x0b}, [32]byte{0x0c}
+	interlink := []*[32]byte{&a, &b, &c}
+	cases := []struct {
+		name                                  string
+		powDepth, thisTargetDepth, nextTarget int
+		expected                              [][32]byte
+	}{
+		{"MeetsTarget", 0, 0, 0, [][32]byte{ref, b, c}},
+		{"Deeper", 2, 0, 0, [][32]byte{ref, ref, ref}},
+		{"BelowNextTarget", 3, 5, 5, [][32]byte{a, b, c}},
+		{"TargetDecreased", 2, 0, 1, [][32]byte{ref, ref}},
+		{"TargetIncreased", 1, 1, 0, [][32]byte{ref, ref, b, c}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hashes := nextInterlinkHashes(ref, c.powDepth, c.thisTargetDepth, c.nextTarget, interlink)
+			assert.Equal(t, c.expected, hashes)
+		})
+	}
+}
+
+func TestBlock_NextInterlink(t *testing.T) {
+	genesis := &Block{Header: *testGenesisHeader(t)}
+	genesisHash := genesis.Header.Hash()
+	genesisPow := genesis.Header.PowHash()
+	interlink := genesis.NextInterlink(&genesisPow, genesis.Header.Target())
+	// The genesis PoW hash reaches depth 0 of the maximum target.
+	require.Len(t, interlink.Hashes, 1)
+	assert.Equal(t, genesisHash, *interlink.Hashes[0])
+
+	next := &Block{
+		Header: BlockHeader{
+			Version:  BlockVersion1,
+			PrevHash: genesisHash,
+			NBits:    genesis.Header.NBits,
+			Height:   2,
+		},
+		Interlink: *interlink,
+	}
+	next.Header.InterlinkHash = next.Interlink.Hash(&genesisHash)
+	assert.NoError(t, next.VerifyInterlinkHash(&genesisHash))
+	assert.NoError(t, next.VerifyInterlink(genesis, &genesisPow))
+
+	next.Interlink = *NewBlockInterlink(nil, &genesisHash)
+	assert.ErrorIs(t, next.VerifyInterlinkHash(&genesisHash), ErrInterlinkHashMismatch)
+	assert.ErrorIs(t, next.VerifyInterlink(genesis, &genesisPow), ErrInvalidInterlink)
+}
+
+// TestBlock_NextInterlink_MainNet checks the interlink of main net block 2.
+// The difficulty adjustment keeps the maximum target after the genesis block,
+// and the genesis PoW hash reaches depth 0, so block 2 references the genesis block once.
+// The interlink hash was computed from the reference implementation's Merkle layout.
+func TestBlock_NextInterlink_MainNet(t *testing.T) {
+	genesis := &Block{Header: *testGenesisHeader(t)}
+	genesisHash := genesis.Header.Hash()
+	genesisPow := genesis.Header.PowHash()
+	interlink := genesis.NextInterlink(&genesisPow, CompactToTarget(0x1f010000))
+
+	buf, err := beserial.Marshal(nil, interlink)
+	require.NoError(t, err)
+	assert.Equal(t, "0180", hex.EncodeToString(buf))
+	hash := interlink.Hash(&genesisHash)
+	assert.Equal(t,
+		"0492e3986e75ac0d1466b5d6a7694c86839767a30980f8ba0d8c6e48631bc9cd",
+		hex.EncodeToString(hash[:]))
+}
@@ -0,0 +1,67 @@
+package wire
+
+import (
+	"golang.org/x/crypto/blake2b"
+)
+
+// MerkleRoot computes the root of a Merkle tree over the leaf hashes.
+//
+// The leaves are split into two halves (the left one larger for odd counts),
+// and each inner node is the hash of its concatenated child hashes.
+// The root of a single leaf is the leaf itself,
+// the root of an empty tree is the hash of empty data.
+func MerkleRoot(leaves [][32]byte) [32]byte {
+	switch len(leaves) {
+	case 0:
+		return blake2b.Sum256(nil)
+	case 1:
+		return leaves[0]
+	}
+	mid := (len(leaves) + 1) / 2
+	left := MerkleRoot(leaves[:mid])
+	right := MerkleRoot(leaves[mid:])
+	return merkleNode(&left, &right)
+}
+
+func merkleNode(left, right *[32]byte) [32]byte {
+	var node [64]byte
+	copy(node[:32], left[:])
+	copy(node[32:], right[:])
+	return blake2b.Sum256(node[:])
+}
+
+// NewMerklePath builds the path from the leaf at the given index to the Merkle root.
+// The path can be checked against the root with ComputeRootFromHash.
+func NewMerklePath(leaves [][32]byte, index int) MerklePath {
+	var siblings [][32]byte
+	var lefts []bool
+	for len(leaves) > 1 {
+		mid := (len(leaves) + 1) / 2
+		if index < mid {
+			siblings = append(siblings, MerkleRoot(leaves[mid:]))
+			lefts = append(lefts, false)
+			leaves = leaves[:mid]
+		} else {
+			siblings = append(siblings, MerkleRoot(leaves[:mid]))
+			lefts = append(lefts, true)
+			leaves = leaves[mid:]
+			index -= mid
+		}
+	}
+	// The path goes from the leaf up to the root.
+	path := MerklePath{
+		Branches: BitSet{
+			Len:  uint8(len(siblings)),
+			Bits: make([]byte, (len(siblings)+7)/8),
+		},
+		Hashes: make([][32]byte, len(siblings)),
+	}
+	for i := range siblings {
+		j := len(siblings) - 1 - i
+		path.Hashes[i] = siblings[j]
+		if lefts[j] {
+			path.Branches.set(uint8(i))
+		}
+	}
+	return path
+}
@@ -0,0 +1,36 @@
+package wire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	a := blake2b.Sum256([]byte("a"))
+	b := blake2b.Sum256([]byte("b"))
+	c := blake2b.Sum256([]byte("c"))
+	assert.Equal(t, blake2b.Sum256(nil), MerkleRoot(nil))
+	assert.Equal(t, a, MerkleRoot([][32]byte{a}))
+	ab := merkleNode(&a, &b)
+	assert.Equal(t, ab, MerkleRoot([][32]byte{a, b}))
+	// The left subtree gets the extra leaf.
+	assert.Equal(t, merkleNode(&ab, &c), MerkleRoot([][32]byte{a, b, c}))
+}
+
+func TestNewMerklePath(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		leaves := make([][32]byte, n)
+		for i := range leaves {
+			leaves[i] = blake2b.Sum256([]byte{byte(i)})
+		}
+		root := MerkleRoot(leaves)
+		for i := range leaves {
+			path := NewMerklePath(leaves, i)
+			assert.Equal(t, root, path.ComputeRootFromHash(leaves[i]), "leaf %d of %d", i, n)
+			assert.Equal(t, root, path.ComputeRoot([]byte{byte(i)}), "leaf %d of %d", i, n)
+			assert.NotEqual(t, root, path.ComputeRoot([]byte{byte(i + 1)}))
+		}
+	}
+}
@@ -20,3 +20,25 @@ type TxMessage struct {
 func (m *TxMessage) Type() uint64 {
 	return MessageTx
 }
+
+// GetAccountsTreeChunkMessage requests the accounts tree leaves
+// at the given block, starting at a hex-encoded key prefix.
+type GetAccountsTreeChunkMessage struct {
+	BlockHash   [32]byte
+	StartPrefix string `beserial:"len_tag=uint8"`
+}
+
+func (m *GetAccountsTreeChunkMessage) Type() uint64 {
+	return MessageGetAccountsTreeChunk
+}
+
+// AccountsTreeChunkMessage answers a GetAccountsTreeChunkMessage.
+// The chunk is missing if the block state is not available.
+type AccountsTreeChunkMessage struct {
+	BlockHash [32]byte
+	Chunk     *AccountsTreeChunk `beserial:"optional"`
+}
+
+func (m *AccountsTreeChunkMessage) Type() uint64 {
+	return MessageAccountsTreeChunk
+}
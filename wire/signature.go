@@ -1,6 +1,7 @@
 package wire
 
 import (
+	"crypto/ed25519"
 	"fmt"
 
 	"golang.org/x/crypto/blake2b"
@@ -13,6 +14,14 @@ type SignatureProof struct {
 	Signature  [64]byte
 }
 
+// Verify checks the Ed25519 signature of the message.
+func (s *SignatureProof) Verify(msg []byte) error {
+	if !ed25519.Verify(s.PublicKey[:], msg, s.Signature[:]) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
 func (s *SignatureProof) SignerAddress() (addr [20]byte) {
 	merkleRoot := s.MerklePath.ComputeRoot(s.PublicKey[:])
 	copy(addr[:], merkleRoot[:]) // Take leftmost bytes of merkle root
@@ -66,8 +75,14 @@ func (mp *MerklePath) SizeBESerial() (n int, err error) {
 	return
 }
 
+// ComputeRoot hashes the leaf value and computes the Merkle root along the path.
 func (mp *MerklePath) ComputeRoot(leafValue []byte) (root [32]byte) {
-	root = blake2b.Sum256(leafValue[:])
+	return mp.ComputeRootFromHash(blake2b.Sum256(leafValue[:]))
+}
+
+// ComputeRootFromHash computes the Merkle root along the path, starting at the leaf hash.
+func (mp *MerklePath) ComputeRootFromHash(leaf [32]byte) (root [32]byte) {
+	root = leaf
 	for i, hash := range mp.Hashes {
 		var node [64]byte
 		if mp.Branches.bit(uint8(i)) {
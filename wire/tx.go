@@ -1,8 +1,10 @@
 package wire
 
 import (
+	"encoding/hex"
 	"fmt"
 
+	"golang.org/x/crypto/blake2b"
 	"terorie.dev/nimiq/beserial"
 )
 
@@ -14,6 +16,8 @@ type Tx interface {
 	AsExtendedTx() ExtendedTx
 	GetSender() (addr *[20]byte, typ uint8)
 	GetRecipient() (addr *[20]byte, typ uint8)
+	// Hash returns the canonical transaction hash (ID).
+	Hash() [32]byte
 }
 
 // WrapTx implements BESerial for Tx.
@@ -71,6 +75,31 @@ func (wt WrapTx) SizeBESerial() (n int, err error) {
 	return
 }
 
+// Hash returns the canonical hash of the wrapped transaction.
+func (wt WrapTx) Hash() [32]byte {
+	return wt.Tx.Hash()
+}
+
+// HashHex returns the transaction hash in hex, as displayed by block explorers.
+func (wt WrapTx) HashHex() string {
+	hash := wt.Tx.Hash()
+	return hex.EncodeToString(hash[:])
+}
+
+// InvVector returns the inventory vector announcing the transaction.
+func (wt WrapTx) InvVector() InvVector {
+	return InvVector{Type: InvTx, Hash: wt.Tx.Hash()}
+}
+
+// ParseTxHash decodes a hex transaction hash.
+func ParseTxHash(s string) (hash [32]byte, err error) {
+	if hex.DecodedLen(len(s)) != len(hash) {
+		return hash, fmt.Errorf("invalid tx hash length: %d", len(s))
+	}
+	_, err = hex.Decode(hash[:], []byte(s))
+	return
+}
+
 // TxContent is a simplified representation of a transaction.
 // It contains all common fields but omits some details.
 // Also, it's used for signing, implying for omitted fields are not security-relevant.
@@ -86,3 +115,13 @@ type TxContent struct {
 	NetworkID           uint8
 	Flags               uint8
 }
+
+// Hash returns the Blake2b hash of the serialized content.
+// Since the content omits proofs, the hash is known before signing.
+func (c *TxContent) Hash() [32]byte {
+	buf, err := beserial.Marshal(nil, c)
+	if err != nil {
+		panic("failed to serialize transaction content: " + err.Error())
+	}
+	return blake2b.Sum256(buf)
+}
@@ -64,4 +64,10 @@ func (t *BasicTx) GetRecipient() (addr *[20]byte, typ uint8) {
 	return &t.Recipient, AccountBasic
 }
 
+// Hash returns the Blake2b hash of the transaction content.
+func (t *BasicTx) Hash() [32]byte {
+	content := t.AsTxContent()
+	return content.Hash()
+}
+
 var _ Tx = (*BasicTx)(nil)
@@ -54,4 +54,10 @@ func (t *ExtendedTx) GetRecipient() (addr *[20]byte, typ uint8) {
 	return &t.Recipient, t.RecipientType
 }
 
+// Hash returns the Blake2b hash of the transaction content.
+func (t *ExtendedTx) Hash() [32]byte {
+	content := t.AsTxContent()
+	return content.Hash()
+}
+
 var _ Tx = (*ExtendedTx)(nil)
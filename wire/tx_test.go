@@ -0,0 +1,85 @@
+package wire
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+	"terorie.dev/nimiq/beserial"
+)
+
+func TestTx_Hash(t *testing.T) {
+	basic := &BasicTx{
+		SenderPubKey:        [32]byte{0x01},
+		Recipient:           [20]byte{0x02},
+		Value:               100,
+		Fee:                 1,
+		ValidityStartHeight: 1000,
+		NetworkID:           42,
+		Signature:           [64]byte{0x03},
+	}
+	content := basic.AsTxContent()
+	buf, err := beserial.Marshal(nil, &content)
+	require.NoError(t, err)
+	hash := basic.Hash()
+	assert.Equal(t, blake2b.Sum256(buf), hash)
+
+	// The extended equivalent has the same ID.
+	extended := basic.AsExtendedTx()
+	assert.Equal(t, hash, extended.Hash())
+	assert.Equal(t, hash, WrapTx{Tx: &extended}.Hash())
+
+	// Proofs are not part of the hash.
+	basic.Signature[0]++
+	assert.Equal(t, hash, basic.Hash())
+	basic.Value++
+	assert.NotEqual(t, hash, basic.Hash())
+
+	// Hex round trip.
+	wt := WrapTx{Tx: &extended}
+	parsed, err := ParseTxHash(wt.HashHex())
+	require.NoError(t, err)
+	assert.Equal(t, hash, parsed)
+	assert.Equal(t, InvVector{Type: InvTx, Hash: hash}, wt.InvVector())
+	_, err = ParseTxHash("abcd")
+	assert.Error(t, err)
+}
+
+func TestTx_HashKnownAnswer(t *testing.T) {
+	// The basic and extended transactions are the signed vectors of the wallet package.
+	// Expected hashes are Blake2b over the content layout of core-js Transaction.serializeContent,
+	// computed independently of TxContent.
+	cases := []struct {
+		name string
+		tx   string
+		hash string
+	}{
+		{
+			name: "Basic",
+			tx:   "002a39f666099582d659112b8c196630958095805f6b1016400030ed0b182064d1000000000000000000000000000000000000000000000000000001a40000000000000539000000632a6a6d2fad4a136a9409aeb935c62af7a356e86435829d94f8a97ddc5fc5e5e202ffedfdf7dc438cc48dd5883edc9b07a2971cafaca520cb34f00a3eb4834be60f",
+			hash: "fe8891853f7aa9255c114a63821055767c92eb0fdf341f2efd3adea29acb437f",
+		},
+		{
+			name: "Extended",
+			tx:   "010000a1f5245a31b48f1f0e4839c2b1b260a89317e85500000000000000000000000000000000000000000000000000000000053900000000000001a4000000632a0000612a39f666099582d659112b8c196630958095805f6b1016400030ed0b182064d1002cf0150ce3fddd6d3d70de801a5e0744e8b3fc946a4380cc134127015e32b13580accc717c7af05e0334fe7ada1e99920e7dd1e03a45091db3986c08c977fc09",
+			hash: "239199af19567a3c05914fcc88b643d920060f1a52b662be01aa6f6084d74a9a",
+		},
+		{
+			// Test net vesting contract creation with data.
+			name: "ContractCreation",
+			tx:   "01000568656c6c6fa1f5245a31b48f1f0e4839c2b1b260a89317e8550011111111111111111111111111111111111111110100000000000186a0000000000000008a000003e801010000",
+			hash: "bad2f48c1b5877e296da747df5dab54aa134cea1f3c4997c4455a356efe26315",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			buf, err := hex.DecodeString(c.tx)
+			require.NoError(t, err)
+			var wt WrapTx
+			require.NoError(t, beserial.UnmarshalFull(buf, &wt))
+			assert.Equal(t, c.hash, wt.HashHex())
+		})
+	}
+}
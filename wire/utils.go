@@ -12,20 +12,32 @@ func TargetToCompact(target big.Int) (compact uint32) {
 
 	// If the first (most significant) byte is
 	// greater than 127 (0x7f), prepend a zero byte.
-	if tgtBytes[0] >= 0x80 && len(tgtBytes) >= 3 {
-		compact |= uint32(len(tgtBytes)+1) << 24
-		compact |= uint32(tgtBytes[0]) << 8
-		compact |= uint32(tgtBytes[1])
-	} else {
-		compact |= uint32(len(tgtBytes)) << 24
-		compact |= uint32(tgtBytes[0]) << 16
-		compact |= uint32(tgtBytes[1]) << 8
-		compact |= uint32(tgtBytes[2])
+	if len(tgtBytes) >= 3 && tgtBytes[0] >= 0x80 {
+		tgtBytes = append([]byte{0x00}, tgtBytes...)
 	}
+	compact |= uint32(len(tgtBytes)) << 24
+
+	// Short targets are padded with zero bytes.
+	var mantissa [3]byte
+	copy(mantissa[:], tgtBytes)
+	compact |= uint32(mantissa[0]) << 16
+	compact |= uint32(mantissa[1]) << 8
+	compact |= uint32(mantissa[2])
 
 	return
 }
 
+// CompactToTarget converts the compact "n-bits" representation into a target hash number.
+func CompactToTarget(compact uint32) (target big.Int) {
+	target.SetUint64(uint64(compact & 0xffffff))
+	if shift := int(compact>>24) - 3; shift >= 0 {
+		target.Lsh(&target, uint(8*shift))
+	} else {
+		target.Rsh(&target, uint(-8*shift))
+	}
+	return
+}
+
 // DifficultyToCompact converts a difficulty number into the compact "n-bits" representation.
 func DifficultyToCompact(difficulty big.Int) uint32 {
 	return TargetToCompact(DifficultyToTarget(difficulty))
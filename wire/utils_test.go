@@ -0,0 +1,33 @@
+package wire
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"terorie.dev/nimiq/policy"
+)
+
+func TestCompactToTarget(t *testing.T) {
+	target := CompactToTarget(0x1f010000)
+	assert.Equal(t, 0, target.Cmp(&policy.BlockTargetMax))
+
+	target = CompactToTarget(0x02012345)
+	assert.Equal(t, int64(0x0123), target.Int64())
+
+	var expected big.Int
+	expected.SetString("00000000ffff0000000000000000000000000000000000000000000000000000", 16)
+	target = CompactToTarget(0x1d00ffff)
+	assert.Equal(t, 0, target.Cmp(&expected))
+	assert.Equal(t, uint32(0x1d00ffff), TargetToCompact(target))
+}
+
+func TestTargetToCompact(t *testing.T) {
+	assert.Equal(t, uint32(0x1f010000), TargetToCompact(policy.BlockTargetMax))
+	// Targets shorter than three bytes get no zero byte, like in the reference implementation.
+	assert.Equal(t, uint32(0x01800000), TargetToCompact(*big.NewInt(0x80)))
+	assert.Equal(t, uint32(0x02800000), TargetToCompact(*big.NewInt(0x8000)))
+	assert.Equal(t, uint32(0x04008000), TargetToCompact(*big.NewInt(0x800000)))
+	assert.Equal(t, uint32(0x02012300), TargetToCompact(*big.NewInt(0x0123)))
+	assert.Equal(t, uint32(0x01010000), TargetToCompact(*big.NewInt(1)))
+}
@@ -0,0 +1,74 @@
+package wire
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"terorie.dev/nimiq/beserial"
+)
+
+// Transaction verification errors.
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrWrongSigner      = errors.New("signed by wrong key")
+)
+
+// NetworkMismatchError is returned when a transaction was created for a different network.
+type NetworkMismatchError struct {
+	Expected, Got uint8
+}
+
+func (n *NetworkMismatchError) Error() string {
+	return fmt.Sprintf("transaction for network %d, expected %d", n.Got, n.Expected)
+}
+
+// VerifyTx checks that the transaction belongs to the specified network
+// and that all signatures in its proof are valid.
+//
+// It does not check whether signers are allowed to spend from the sender account,
+// except for basic accounts, whose address is derived from the signer's key.
+// Contracts check their signers when applying outgoing transactions.
+func VerifyTx(tx Tx, networkID uint8) error {
+	content := tx.AsTxContent()
+	if content.NetworkID != networkID {
+		return &NetworkMismatchError{Expected: networkID, Got: content.NetworkID}
+	}
+	msg, err := beserial.Marshal(nil, &content)
+	if err != nil {
+		return err
+	}
+	switch t := tx.(type) {
+	case *BasicTx:
+		if !ed25519.Verify(t.SenderPubKey[:], msg, t.Signature[:]) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case *ExtendedTx:
+		return verifyExtendedTx(t, msg)
+	default:
+		panic(fmt.Sprintf("unsupported tx type: %T", tx))
+	}
+}
+
+func verifyExtendedTx(tx *ExtendedTx, msg []byte) error {
+	switch tx.SenderType {
+	case AccountBasic, AccountVesting:
+		var proof SignatureProof
+		if err := beserial.UnmarshalFull(tx.Proof, &proof); err != nil {
+			return fmt.Errorf("in wire.SignatureProof: %w", err)
+		}
+		if tx.SenderType == AccountBasic && proof.SignerAddress() != tx.Sender {
+			return ErrWrongSigner
+		}
+		return proof.Verify(msg)
+	case AccountHTLC:
+		var proof HTLCProof
+		if err := beserial.UnmarshalFull(tx.Proof, &proof); err != nil {
+			return fmt.Errorf("in wire.HTLCProof: %w", err)
+		}
+		return proof.Verify(msg)
+	default:
+		return fmt.Errorf("invalid sender type: %d", tx.SenderType)
+	}
+}
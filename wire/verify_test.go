@@ -0,0 +1,61 @@
+package wire
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"terorie.dev/nimiq/beserial"
+)
+
+func testSignatureProof(t *testing.T, tx Tx) SignatureProof {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	var proof SignatureProof
+	copy(proof.PublicKey[:], pub)
+	content := tx.AsTxContent()
+	msg, err := beserial.Marshal(nil, &content)
+	require.NoError(t, err)
+	copy(proof.Signature[:], ed25519.Sign(priv, msg))
+	return proof
+}
+
+func TestVerifyTx_HTLC(t *testing.T) {
+	tx := &ExtendedTx{
+		SenderType:          AccountHTLC,
+		Recipient:           [20]byte{0x01},
+		RecipientType:       AccountBasic,
+		Value:               100,
+		ValidityStartHeight: 1,
+		NetworkID:           42,
+	}
+	recipientProof := testSignatureProof(t, tx)
+	senderProof := testSignatureProof(t, tx)
+	proof := HTLCProof{
+		Type:           HTLCEarlyResolve,
+		RecipientProof: recipientProof,
+		SenderProof:    senderProof,
+	}
+	var err error
+	tx.Proof, err = beserial.Marshal(nil, &proof)
+	require.NoError(t, err)
+	size, err := beserial.Size(&proof)
+	require.NoError(t, err)
+	assert.Len(t, tx.Proof, size)
+
+	var decoded HTLCProof
+	require.NoError(t, beserial.UnmarshalFull(tx.Proof, &decoded))
+	assert.Equal(t, HTLCEarlyResolve, decoded.Type)
+	assert.Equal(t, recipientProof.PublicKey, decoded.RecipientProof.PublicKey)
+	assert.Equal(t, senderProof.Signature, decoded.SenderProof.Signature)
+
+	assert.NoError(t, VerifyTx(tx, 42))
+
+	// Swap in a signature over different data.
+	badProof := testSignatureProof(t, &BasicTx{})
+	proof.SenderProof = badProof
+	tx.Proof, err = beserial.Marshal(nil, &proof)
+	require.NoError(t, err)
+	assert.ErrorIs(t, VerifyTx(tx, 42), ErrInvalidSignature)
+}